@@ -0,0 +1,104 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HubTemplatePermissionSpec selects the managed clusters and the permissions granted to them.
+type HubTemplatePermissionSpec struct {
+	// ClusterSelector selects the managed clusters by their labels. An empty selector selects all
+	// of them. Without a selector and cluster sets, no managed clusters are selected.
+	// +optional
+	ClusterSelector *metav1.LabelSelector `json:"clusterSelector,omitempty"`
+
+	// ClusterSets selects the managed clusters of the ManagedClusterSets, through the cluster
+	// selectors of the sets.
+	// +optional
+	ClusterSets []string `json:"clusterSets,omitempty"`
+
+	// Rules are the read-only rules granted to the selected managed clusters.
+	// +kubebuilder:validation:MinItems=1
+	Rules []HubTemplatePermissionRule `json:"rules"`
+}
+
+// HubTemplatePermissionRule is a read-only rule, granted in each of the namespaces, or
+// cluster-wide when no namespaces are listed. It can't grant secrets, or use the * wildcard in
+// its API groups and resources.
+type HubTemplatePermissionRule struct {
+	// APIGroups are the API groups of the resources.
+	APIGroups []string `json:"apiGroups"`
+
+	// Resources are the resources granted in the API groups.
+	// +kubebuilder:validation:MinItems=1
+	Resources []string `json:"resources"`
+
+	// ResourceNames optionally restricts the rule to the resources with these names.
+	// +optional
+	ResourceNames []string `json:"resourceNames,omitempty"`
+
+	// Verbs are the verbs granted on the resources, only get, list and watch.
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:items:Enum=get;list;watch
+	Verbs []string `json:"verbs"`
+
+	// Namespaces are the hub namespaces where the rule is granted. The rule is granted
+	// cluster-wide when there are none.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// HubTemplatePermissionStatus reports the managed clusters granted the permissions.
+type HubTemplatePermissionStatus struct {
+	// Clusters is the number of managed clusters granted the permissions.
+	// +optional
+	Clusters int32 `json:"clusters"`
+
+	// Conditions report whether the HubTemplatePermission was reconciled.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Clusters",type=integer,JSONPath=`.status.clusters`
+//+kubebuilder:printcolumn:name="Reconciled",type=string,JSONPath=`.status.conditions[?(@.type=="Reconciled")].status`
+
+// HubTemplatePermission grants the governance-standalone-hub-templating addon of the selected
+// managed clusters read access to hub resources, for the hub templates of their policies.
+type HubTemplatePermission struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HubTemplatePermissionSpec   `json:"spec"`
+	Status HubTemplatePermissionStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// HubTemplatePermissionList contains a list of HubTemplatePermission
+type HubTemplatePermissionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HubTemplatePermission `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HubTemplatePermission{}, &HubTemplatePermissionList{})
+}
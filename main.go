@@ -22,7 +22,6 @@ import (
 	"fmt"
 	"os"
 	"runtime"
-	"sync"
 
 	"github.com/go-logr/zapr"
 	"github.com/openshift/library-go/pkg/controller/controllercmd"
@@ -38,6 +37,7 @@ import (
 	"open-cluster-management.io/addon-framework/pkg/addonmanager"
 	ctrl "sigs.k8s.io/controller-runtime"
 
+	policyaddon "open-cluster-management.io/governance-policy-addon-controller/pkg/addon"
 	"open-cluster-management.io/governance-policy-addon-controller/pkg/addon/configpolicy"
 	"open-cluster-management.io/governance-policy-addon-controller/pkg/addon/policyframework"
 	"open-cluster-management.io/governance-policy-addon-controller/pkg/addon/standalonetemplating"
@@ -46,7 +46,7 @@ import (
 //+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=get;create
 //+kubebuilder:rbac:groups=certificates.k8s.io,resources=certificatesigningrequests;certificatesigningrequests/approval,verbs=get;list;watch;create;update
 //+kubebuilder:rbac:groups=certificates.k8s.io,resources=signers,verbs=approve
-//+kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=managedclusters,verbs=get;list;watch
+//+kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=managedclusters;managedclustersets,verbs=get;list;watch
 //+kubebuilder:rbac:groups=addon.open-cluster-management.io,resources=clustermanagementaddons,verbs=get;list;watch
 
 // RBAC below will need to be updated if/when new policy controllers are added.
@@ -61,6 +61,14 @@ import (
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=create
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;update;patch;delete,resourceNames="open-cluster-management:policy-framework-hub";"open-cluster-management:config-policy-controller-hub";"open-cluster-management:governance-standalone-hub-templating"
 
+// The HubTemplatePermission controller manages labeled roles and bindings with dynamic names. Without
+// bind and escalate, it can only grant the permissions it holds itself, which are aggregated to the
+// governance-policy-addon-controller-hub-template-permissions ClusterRole. The stale roles and
+// bindings are emptied rather than deleted, and garbage collected with their HubTemplatePermission.
+//+kubebuilder:rbac:groups=policy.open-cluster-management.io,resources=hubtemplatepermissions,verbs=get;list;watch
+//+kubebuilder:rbac:groups=policy.open-cluster-management.io,resources=hubtemplatepermissions/status,verbs=update;patch
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings;clusterroles;clusterrolebindings,verbs=get;list;watch;create;update
+
 // Cannot limit based on resourceNames because the name is dynamic in hosted mode.
 //+kubebuilder:rbac:groups=work.open-cluster-management.io,resources=manifestworks,verbs=create;delete;get;list;patch;update;watch
 
@@ -84,7 +92,11 @@ import (
 //+kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=clusterclaims,resourceNames=id.k8s.io,verbs=get
 //+kubebuilder:rbac:groups=core;events.k8s.io,resources=events,verbs=create;get;list;patch;update;watch
 //+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+// The post-render patches ConfigMaps are cached with a label selector, which RBAC can't restrict
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=list;watch
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get,resourceNames=cluster-info
 //+kubebuilder:rbac:groups=config.openshift.io,resources=infrastructures,verbs=get;list;watch
+//+kubebuilder:rbac:groups=config.openshift.io,resources=apiservers,verbs=get
 
 var (
 	ctrlVersion = version.Info{}
@@ -94,6 +106,14 @@ var (
 		LevelName:   "log-level",
 		EncoderName: "log-encoder",
 	}
+	// Directory of additional addons to load from disk
+	addonsDir string
+	// Directory of files replacing the matching files in the addons' charts
+	chartOverlayDir string
+	// URL of the hub API server that the managed clusters connect to
+	hubAPIServer string
+	// Controller configuration file, reloaded when it changes
+	controllerConfigFile string
 )
 
 const (
@@ -131,11 +151,22 @@ func main() {
 	ctrlcmd := ctrlconfig.NewCommandWithContext(context.TODO())
 	ctrlcmd.Use = ctrlName
 	ctrlcmd.Short = "Governance policy addon controller for Open Cluster Management"
+	ctrlcmd.Flags().StringVar(&addonsDir, "addons-dir", "",
+		"Directory containing additional policy addons to manage, one per subdirectory")
+	ctrlcmd.Flags().StringVar(&chartOverlayDir, "chart-overlay-dir", "",
+		"Directory of files replacing the matching files in the addon charts, in <addon>/<chart path> subdirectories")
+	ctrlcmd.Flags().StringVar(&hubAPIServer, "hub-api-server", "",
+		"URL of the hub API server, added to the addons' no-proxy hosts (discovered from kube-public/cluster-info if unset)")
+	ctrlcmd.Flags().StringVar(&controllerConfigFile, "controller-config", "",
+		"Controller configuration file with the images, feature toggles and default customized variables, "+
+			"reloaded when it changes")
 
 	if err := ctrlcmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 
-		klog.Fatal()
+		// Deferred functions don't run on exit
+		logs.FlushLogs()
+		os.Exit(1) //nolint:gocritic // the logs are flushed above
 	}
 }
 
@@ -146,38 +177,52 @@ func runController(ctx context.Context, controllerContext *controllercmd.Control
 
 	mgr, err := addonmanager.New(controllerContext.KubeConfig)
 	if err != nil {
-		log.Error(err, "unable to create new addon manager")
-		os.Exit(1)
+		return fmt.Errorf("unable to create new addon manager: %w", err)
 	}
 
-	agentFuncs := []func(context.Context, addonmanager.AddonManager, *controllercmd.ControllerContext) error{
-		policyframework.GetAndAddAgent,
-		configpolicy.GetAndAddAgent,
-		standalonetemplating.GetAndAddAgent,
+	descriptors := []*policyaddon.AgentDescriptor{
+		policyframework.Descriptor,
+		configpolicy.Descriptor,
+		standalonetemplating.Descriptor,
 	}
 
-	wg := sync.WaitGroup{}
-
-	for _, f := range agentFuncs {
-		err := f(ctx, mgr, controllerContext)
+	if addonsDir != "" {
+		externalDescriptors, err := policyaddon.LoadExternalAddons(addonsDir)
 		if err != nil {
-			log.Error(err, "unable to get or add agent addon")
-			os.Exit(1)
+			return fmt.Errorf("unable to load the addons from %s: %w", addonsDir, err)
 		}
-	}
 
-	wg.Go(func() {
-		err = mgr.Start(ctx)
-		if err != nil {
-			log.Error(err, "problem starting manager")
-			os.Exit(1)
+		for _, desc := range externalDescriptors {
+			log.Info("Loaded an addon from disk", "addon", desc.Name, "addonsDir", addonsDir)
 		}
 
-		// mgr.Start is not blocking so wait on the context to finish
-		<-ctx.Done()
-	})
+		descriptors = append(descriptors, externalDescriptors...)
+	}
+
+	if chartOverlayDir != "" {
+		log.Info("Overriding the addon charts with the chart overlay directory", "chartOverlayDir", chartOverlayDir)
+	}
+
+	opts := policyaddon.AgentOptions{
+		ChartOverlayDir:      chartOverlayDir,
+		HubAPIServer:         hubAPIServer,
+		ControllerConfigFile: controllerConfigFile,
+	}
+
+	err = policyaddon.AddAgents(ctx, mgr, controllerContext, opts, descriptors...)
+	if err != nil {
+		return fmt.Errorf("unable to get or add agent addon: %w", err)
+	}
+
+	err = mgr.Start(ctx)
+	if err != nil {
+		return fmt.Errorf("problem starting manager: %w", err)
+	}
+
+	// mgr.Start is not blocking so wait on the context to finish, which is canceled on SIGTERM
+	<-ctx.Done()
 
-	wg.Wait()
+	log.Info("Shutting down " + ctrlName)
 
 	return nil
 }
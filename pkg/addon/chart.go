@@ -0,0 +1,363 @@
+package addon
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1client "open-cluster-management.io/api/client/cluster/clientset/versioned"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+// chartAgentAddon renders a Helm chart read from an fs.FS. It mirrors the Helm
+// agent addon built by the addonfactory, which only accepts an embed.FS, so that
+// charts that aren't compiled in go through the same values and rendering steps.
+// It is only used for the addons loaded from disk and the addons with a chart
+// overlay, and the other addons use the addonfactory.
+type chartAgentAddon struct {
+	decoder        runtime.Decoder
+	chartFS        fs.FS
+	getValuesFuncs []addonfactory.GetValuesFunc
+	options        agent.AgentAddonOptions
+	clusterClient  clusterv1client.Interface
+
+	lock  sync.RWMutex
+	chart *chart.Chart
+}
+
+// chartBuiltinValues are the values set by the addon framework which can't be
+// overridden by the values functions.
+type chartBuiltinValues struct {
+	ClusterName           string `json:"clusterName"`
+	AddonInstallNamespace string `json:"addonInstallNamespace"`
+	InstallMode           string `json:"installMode"`
+}
+
+// chartDefaultValues are the values set by the addon framework which can be
+// overridden by the values functions.
+type chartDefaultValues struct {
+	HubKubeConfigSecret        string                 `json:"hubKubeConfigSecret,omitempty"`
+	ManagedKubeConfigSecret    string                 `json:"managedKubeConfigSecret,omitempty"`
+	HostingClusterCapabilities chartutil.Capabilities `json:"hostingClusterCapabilities,omitempty"`
+}
+
+// newChartAgentAddon returns an agent addon for the chart in ChartDir of the
+// filesystem. The chart is loaded once, and then again by reloadChart when the
+// filesystem changes.
+func newChartAgentAddon(
+	chartFS fs.FS,
+	options agent.AgentAddonOptions,
+	clusterClient clusterv1client.Interface,
+	getValuesFuncs ...addonfactory.GetValuesFunc,
+) (*chartAgentAddon, error) {
+	if options.HostedModeInfoFunc == nil {
+		options.HostedModeInfoFunc = constants.GetHostedModeInfo
+	}
+
+	loadedChart, err := loadChart(chartFS)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chartAgentAddon{
+		decoder:        serializer.NewCodecFactory(Scheme).UniversalDeserializer(),
+		chartFS:        chartFS,
+		getValuesFuncs: getValuesFuncs,
+		options:        options,
+		clusterClient:  clusterClient,
+		chart:          loadedChart,
+	}, nil
+}
+
+// reloadChart loads the chart from the filesystem again. The previous chart is kept when the
+// new one fails to load.
+func (a *chartAgentAddon) reloadChart() error {
+	loadedChart, err := loadChart(a.chartFS)
+	if err != nil {
+		return err
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.chart = loadedChart
+
+	return nil
+}
+
+func (a *chartAgentAddon) loadedChart() *chart.Chart {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	return a.chart
+}
+
+// loadChart loads the Helm chart from ChartDir of the filesystem.
+func loadChart(chartFS fs.FS) (*chart.Chart, error) {
+	var files []*loader.BufferedFile
+
+	err := fs.WalkDir(chartFS, ChartDir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		data, err := fs.ReadFile(chartFS, filePath)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, &loader.BufferedFile{
+			Name: strings.TrimPrefix(filePath, ChartDir+"/"),
+			Data: data,
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the chart files: %w", err)
+	}
+
+	loadedChart, err := loader.LoadFiles(files)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the chart: %w", err)
+	}
+
+	return loadedChart, nil
+}
+
+func (a *chartAgentAddon) GetAgentAddonOptions() agent.AgentAddonOptions {
+	return a.options
+}
+
+func (a *chartAgentAddon) Manifests(
+	ctx context.Context,
+	cluster *clusterv1.ManagedCluster,
+	addon *addonapiv1beta1.ManagedClusterAddOn,
+) ([]runtime.Object, error) {
+	loadedChart := a.loadedChart()
+
+	values, err := a.getValues(ctx, loadedChart, cluster, addon)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := []runtime.Object{}
+
+	for _, crd := range loadedChart.CRDObjects() {
+		object, _, err := a.decoder.Decode(crd.File.Data, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, object)
+	}
+
+	templates, err := engine.Engine{}.Render(loadedChart, values)
+	if err != nil {
+		return nil, err
+	}
+
+	// Render the templates in a stable order
+	templateNames := make([]string, 0, len(templates))
+	for name := range templates {
+		templateNames = append(templateNames, name)
+	}
+
+	sort.Strings(templateNames)
+
+	for _, name := range templateNames {
+		if path.Base(name) == "NOTES.txt" || strings.TrimSpace(templates[name]) == "" {
+			continue
+		}
+
+		yamlReader := yaml.NewYAMLReader(bufio.NewReader(strings.NewReader(templates[name])))
+
+		for {
+			raw, err := yamlReader.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			if len(strings.TrimSpace(string(raw))) == 0 {
+				continue
+			}
+
+			object, _, err := a.decoder.Decode(raw, nil, nil)
+			if err != nil {
+				// Like the addonfactory, skip kinds that aren't known to the scheme
+				if runtime.IsMissingKind(err) {
+					log.V(2).Info("Skipping a manifest with an unknown kind", "template", name, "error", err.Error())
+
+					continue
+				}
+
+				return nil, fmt.Errorf("failed to decode template %s: %w", name, err)
+			}
+
+			objects = append(objects, object)
+		}
+	}
+
+	return sortByInstallOrder(objects)
+}
+
+func (a *chartAgentAddon) getValues(
+	ctx context.Context,
+	loadedChart *chart.Chart,
+	cluster *clusterv1.ManagedCluster,
+	addon *addonapiv1beta1.ManagedClusterAddOn,
+) (chartutil.Values, error) {
+	installMode, hostingClusterName := a.options.HostedModeInfoFunc(addon, cluster)
+
+	defaultValues := chartDefaultValues{
+		ManagedKubeConfigSecret: addon.Name + "-managed-kubeconfig",
+	}
+
+	if a.options.Registration != nil {
+		defaultValues.HubKubeConfigSecret = a.options.AddonName + "-hub-kubeconfig"
+	}
+
+	if hostingClusterName != "" && a.clusterClient != nil {
+		hostingCluster, err := a.clusterClient.ClusterV1().ManagedClusters().Get(
+			ctx, hostingClusterName, metav1.GetOptions{},
+		)
+		if err == nil {
+			defaultValues.HostingClusterCapabilities = *capabilities(hostingCluster)
+		} else if !k8serrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	overrideValues, err := addonfactory.JsonStructToValues(defaultValues)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, getValuesFunc := range a.getValuesFuncs {
+		userValues, err := getValuesFunc(cluster, addon)
+		if err != nil {
+			return nil, err
+		}
+
+		overrideValues = addonfactory.MergeValues(overrideValues, userValues)
+	}
+
+	installNamespace, err := a.installNamespace(ctx, addon)
+	if err != nil {
+		return nil, err
+	}
+
+	builtinValues, err := addonfactory.JsonStructToValues(chartBuiltinValues{
+		ClusterName:           cluster.Name,
+		AddonInstallNamespace: installNamespace,
+		InstallMode:           installMode,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	overrideValues = addonfactory.MergeValues(overrideValues, builtinValues)
+
+	return chartutil.ToRenderValues(loadedChart, overrideValues,
+		chartutil.ReleaseOptions{Name: a.options.AddonName, Namespace: installNamespace},
+		capabilities(cluster),
+	)
+}
+
+func (a *chartAgentAddon) installNamespace(
+	ctx context.Context, addon *addonapiv1beta1.ManagedClusterAddOn,
+) (string, error) {
+	installNamespace := addon.Annotations[addonapiv1beta1.InstallNamespaceAnnotation]
+	if installNamespace == "" {
+		installNamespace = addonfactory.AddonDefaultInstallNamespace
+	}
+
+	if a.options.AgentInstallNamespace != nil {
+		ns, err := a.options.AgentInstallNamespace(ctx, addon)
+		if err != nil {
+			return "", err
+		}
+
+		if ns != "" {
+			installNamespace = ns
+		}
+	}
+
+	return installNamespace, nil
+}
+
+// capabilities only provides the Kubernetes version, like the addonfactory.
+func capabilities(cluster *clusterv1.ManagedCluster) *chartutil.Capabilities {
+	return &chartutil.Capabilities{
+		KubeVersion: chartutil.KubeVersion{Version: cluster.Status.Version.Kubernetes},
+	}
+}
+
+// sortByInstallOrder sorts the objects by kind in the order Helm would install them,
+// keeping the order of objects with the same kind. Unknown kinds are sorted last.
+func sortByInstallOrder(objects []runtime.Object) ([]runtime.Object, error) {
+	ordering := make(map[string]int, len(releaseutil.InstallOrder))
+	for i, kind := range releaseutil.InstallOrder {
+		ordering[kind] = i
+	}
+
+	kinds := make(map[runtime.Object]string, len(objects))
+
+	for _, obj := range objects {
+		accessor, err := meta.TypeAccessor(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		kinds[obj] = accessor.GetKind()
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool {
+		kindI, kindJ := kinds[objects[i]], kinds[objects[j]]
+		orderI, okI := ordering[kindI]
+		orderJ, okJ := ordering[kindJ]
+
+		switch {
+		case !okI && !okJ:
+			return kindI < kindJ
+		case !okI:
+			return false
+		case !okJ:
+			return true
+		default:
+			return orderI < orderJ
+		}
+	})
+
+	return objects, nil
+}
@@ -0,0 +1,164 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+//go:embed all:testdata/chart
+var testChartFS embed.FS
+
+// objectKeys returns the kind, namespace and name of the objects, to compare them in order.
+func objectKeys(t *testing.T, objects []runtime.Object) []string {
+	t.Helper()
+
+	keys := make([]string, 0, len(objects))
+
+	for _, obj := range objects {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			t.Fatalf("expected an object with metadata, got: %v", err)
+		}
+
+		keys = append(keys, obj.GetObjectKind().GroupVersionKind().Kind+"/"+
+			accessor.GetNamespace()+"/"+accessor.GetName())
+	}
+
+	return keys
+}
+
+// sortedByKey sorts the objects by their kind, namespace and name, since the addonfactory
+// renders the objects of the same kind in a random order.
+func sortedByKey(t *testing.T, objects []runtime.Object) []runtime.Object {
+	t.Helper()
+
+	sorted := slices.Clone(objects)
+
+	slices.SortStableFunc(sorted, func(a, b runtime.Object) int {
+		return strings.Compare(objectKeys(t, []runtime.Object{a})[0], objectKeys(t, []runtime.Object{b})[0])
+	})
+
+	return sorted
+}
+
+func TestChartAgentAddonMatchesAddonFactory(t *testing.T) {
+	getValues := func(
+		_ *clusterv1.ManagedCluster, addon *addonapiv1beta1.ManagedClusterAddOn,
+	) (addonfactory.Values, error) {
+		return addonfactory.Values{"logLevel": 2, "replicas": len(addon.Annotations)}, nil
+	}
+
+	factoryAddon, err := addonfactory.NewAgentAddonFactory("my-controller", testChartFS, "testdata/chart/"+ChartDir).
+		WithGetValuesFuncs(getValues).
+		WithAgentRegistrationOption(&agent.RegistrationOption{}).
+		WithScheme(Scheme).
+		WithAgentHostedModeEnabledOption().
+		BuildHelmAgentAddon()
+	if err != nil {
+		t.Fatalf("expected the addonfactory to build the addon, got: %v", err)
+	}
+
+	chartFS, err := fs.Sub(testChartFS, "testdata/chart")
+	if err != nil {
+		t.Fatalf("expected the chart filesystem, got: %v", err)
+	}
+
+	chartAddon, err := newChartAgentAddon(chartFS, factoryAddon.GetAgentAddonOptions(), nil, getValues)
+	if err != nil {
+		t.Fatalf("expected the chart addon to be built, got: %v", err)
+	}
+
+	cluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+	cluster.Status.Version.Kubernetes = "v1.30.0"
+
+	tests := map[string]map[string]string{
+		"default mode": nil,
+		"install namespace": {
+			addonapiv1beta1.InstallNamespaceAnnotation: "my-namespace",
+		},
+		"hosted mode": {
+			addonapiv1beta1.HostingClusterNameAnnotationKey: "hosting-cluster",
+		},
+	}
+
+	for name, annotations := range tests {
+		t.Run(name, func(t *testing.T) {
+			addon := &addonapiv1beta1.ManagedClusterAddOn{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-controller", Namespace: "cluster1", Annotations: annotations},
+			}
+
+			expected, err := factoryAddon.Manifests(context.TODO(), cluster, addon)
+			if err != nil {
+				t.Fatalf("expected the addonfactory to render the chart, got: %v", err)
+			}
+
+			objects, err := chartAddon.Manifests(context.TODO(), cluster, addon)
+			if err != nil {
+				t.Fatalf("expected the chart addon to render the chart, got: %v", err)
+			}
+
+			// Both sort the objects by kind in the Helm install order
+			kinds := func(objects []runtime.Object) []string {
+				kinds := []string{}
+				for _, obj := range objects {
+					kinds = append(kinds, obj.GetObjectKind().GroupVersionKind().Kind)
+				}
+
+				return kinds
+			}
+
+			if !slices.Equal(kinds(objects), kinds(expected)) {
+				t.Fatalf("expected the kinds in the order %v, got: %v", kinds(expected), kinds(objects))
+			}
+
+			if !equality.Semantic.DeepEqual(sortedByKey(t, objects), sortedByKey(t, expected)) {
+				t.Fatalf("expected the objects %v to match the addonfactory, got: %v",
+					objectKeys(t, expected), objectKeys(t, objects))
+			}
+		})
+	}
+}
+
+func TestChartAgentAddonReloadChart(t *testing.T) {
+	chartFS, err := fs.Sub(testChartFS, "testdata/chart")
+	if err != nil {
+		t.Fatalf("expected the chart filesystem, got: %v", err)
+	}
+
+	overlayDir := t.TempDir()
+	overlay := newChartOverlayFS(chartFS, overlayDir)
+
+	chartAddon, err := newChartAgentAddon(overlay, agent.AgentAddonOptions{AddonName: "my-controller"}, nil)
+	if err != nil {
+		t.Fatalf("expected the chart addon to be built, got: %v", err)
+	}
+
+	writeTestAddon(t, overlayDir, map[string]string{"values.yaml": "replicas: 3\n"})
+
+	if chartAddon.loadedChart().Values["replicas"] != float64(1) {
+		t.Fatalf("expected the chart to be cached until it is reloaded, got: %v", chartAddon.loadedChart().Values)
+	}
+
+	if err := chartAddon.reloadChart(); err != nil {
+		t.Fatalf("expected the chart to reload, got: %v", err)
+	}
+
+	if chartAddon.loadedChart().Values["replicas"] != float64(3) {
+		t.Fatalf("expected the reloaded chart values, got: %v", chartAddon.loadedChart().Values)
+	}
+}
@@ -2,26 +2,33 @@ package addon
 
 import (
 	"context"
-	"embed"
 	"errors"
 	"fmt"
+	"io/fs"
+	"net"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/openshift/library-go/pkg/assets"
 	"github.com/openshift/library-go/pkg/controller/controllercmd"
 	"github.com/openshift/library-go/pkg/operator/events"
 	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
 	prometheusv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/common/model"
 	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"open-cluster-management.io/addon-framework/pkg/addonfactory"
-	"open-cluster-management.io/addon-framework/pkg/addonmanager"
 	"open-cluster-management.io/addon-framework/pkg/agent"
 	"open-cluster-management.io/addon-framework/pkg/utils"
 	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
 	clusterlistersv1 "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	sdktls "open-cluster-management.io/sdk-go/pkg/tls"
@@ -39,6 +46,10 @@ const (
 	PrometheusEnabledAnnotation     = "prometheus-metrics-enabled"
 	NetworkPoliciesEnabledEnvVar    = "NETWORK_POLICIES_ENABLED"
 
+	// maxReplicas is the maximum number of replicas of an agent. Only the leader evaluates
+	// policies, so more replicas only add standbys.
+	maxReplicas = 5
+
 	AnnotationParseErrorFmt = "Failed to verify '%s' annotation value '%s' for component %s " +
 		"(falling back to default value %v)"
 )
@@ -50,6 +61,19 @@ type CommonValues struct {
 
 	KubernetesDistribution        string `json:"kubernetesDistribution,omitempty"`
 	HostingKubernetesDistribution string `json:"hostingKubernetesDistribution,omitempty"`
+	// DistributionProfile contains the chart defaults for the distribution of the hosting cluster.
+	DistributionProfile *DistributionProfileValues `json:"distributionProfile,omitempty"`
+	Probes              *Probes                    `json:"probes,omitempty"`
+	// Replicas above 1 enable the high-availability mode of the agents: leader election, a
+	// rolling update strategy, pod anti-affinity and a PodDisruptionBudget.
+	Replicas int32 `json:"replicas,omitempty"`
+}
+
+// DistributionProfileValues contains the values of the distribution profile that the charts
+// don't otherwise have.
+type DistributionProfileValues struct {
+	Tolerations        []corev1.Toleration       `json:"tolerations,omitempty"`
+	SeccompProfileType corev1.SeccompProfileType `json:"seccompProfileType,omitempty"`
 }
 
 // UserArgs contains common controller flags for the addon chart.
@@ -70,7 +94,19 @@ type GlobalValues struct {
 	ImagePullSecret string            `json:"imagePullSecret,omitempty"`
 	ImageOverrides  map[string]string `json:"imageOverrides,omitempty"`
 	ProxyConfig     *ProxyConfig      `json:"proxyConfig,omitempty"`
-	NetworkPolicies *NetworkPolicies  `json:"networkPolicies,omitempty"`
+	// ResourceRequirements are matched by container ID, like "deployments:<name>:<container>".
+	ResourceRequirements []ResourceRequirements `json:"resourceRequirements,omitempty"`
+	NetworkPolicies      *NetworkPolicies       `json:"networkPolicies,omitempty"`
+	// CommonLabels and CommonAnnotations are added to every object deployed by the addon.
+	CommonLabels      map[string]string `json:"commonLabels,omitempty"`
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+}
+
+// ResourceRequirements contains the resource requirements of the containers with an ID matching
+// the regular expression.
+type ResourceRequirements struct {
+	ContainerIDRegex string                      `json:"containerIDRegex"`
+	Resources        corev1.ResourceRequirements `json:"resources"`
 }
 
 // ProxyConfig contains proxy configuration values for the addon chart.
@@ -80,17 +116,35 @@ type ProxyConfig struct {
 	HTTPProxy  string `json:"HTTP_PROXY,omitempty"`
 	HTTPSProxy string `json:"HTTPS_PROXY,omitempty"`
 	NoProxy    string `json:"NO_PROXY,omitempty"`
+	// ProxyCABundle is mounted in the agents so they trust the proxy.
+	ProxyCABundle string `json:"PROXY_CA_BUNDLE,omitempty"`
 }
 
 // NetworkPolicies contains network policies configuration values for the addon chart.
 type NetworkPolicies struct {
-	Enabled bool `json:"enabled,omitempty"`
+	// Enabled is always set so that it can be disabled for a cluster.
+	Enabled bool `json:"enabled"`
+	// EgressPorts and EgressCIDRs allow more egress, like to a hub API server on a nonstandard port.
+	EgressPorts []NetworkPolicyPort `json:"egressPorts,omitempty"`
+	EgressCIDRs []string            `json:"egressCIDRs,omitempty"` //nolint:tagliatelle
+	// MetricsNamespace restricts the metrics ingress to the namespace, like the monitoring namespace.
+	MetricsNamespace string `json:"metricsNamespace,omitempty"`
+}
+
+// NetworkPolicyPort is a port allowed by the addon's network policy.
+type NetworkPolicyPort struct {
+	Port     int32           `json:"port"`
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
 }
 
-// GetNetworkPoliciesEnabled reads the environment variable
-// that determines whether network policies should be created.
+// GetNetworkPoliciesEnabled reads the controller configuration, and then the environment
+// variable, that determines whether network policies should be created.
 // Default true.
 func GetNetworkPoliciesEnabled() bool {
+	if enabled := GetControllerConfig().NetworkPoliciesEnabled; enabled != nil {
+		return *enabled
+	}
+
 	defaultVal := true
 
 	value := os.Getenv(NetworkPoliciesEnabledEnvVar)
@@ -121,17 +175,44 @@ type BaseValues struct {
 
 // PrometheusConfig contains Prometheus metrics configuration values for the addon chart.
 type PrometheusConfig struct {
-	Enabled        bool            `json:"enabled,omitempty"`
+	// Enabled is a pointer so that the metrics can be disabled where they are enabled by default.
+	Enabled        *bool           `json:"enabled,omitempty"`
 	ServiceMonitor *ServiceMonitor `json:"serviceMonitor,omitempty"`
+	PrometheusRule *PrometheusRule `json:"prometheusRule,omitempty"`
+	TLS            *MetricsTLS     `json:"tls,omitempty"`
+}
+
+// MetricsTLS contains the configuration values for the metrics serving certificate, when it isn't
+// issued by the OpenShift service CA.
+type MetricsTLS struct {
+	SecretName string             `json:"secretName,omitempty"`
+	Issuer     *CertificateIssuer `json:"issuer,omitempty"`
+}
+
+// CertificateIssuer references the cert-manager issuer of the metrics serving certificate.
+type CertificateIssuer struct {
+	Name string `json:"name,omitempty"`
+	Kind string `json:"kind,omitempty"`
 }
 
 // ServiceMonitor contains Prometheus ServiceMonitor configuration values for the addon chart.
 type ServiceMonitor struct {
-	Namespace *string `json:"namespace,omitempty"`
+	Namespace *string           `json:"namespace,omitempty"`
+	Interval  string            `json:"interval,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// PrometheusRule contains Prometheus alerting rule configuration values for the addon chart.
+type PrometheusRule struct {
+	Enabled bool `json:"enabled"`
 }
 
 var Scheme = runtime.NewScheme()
 
+// CertificateGVK is the cert-manager Certificate kind. It is decoded as unstructured, since the
+// addon framework skips the rendered objects of kinds that aren't in the scheme.
+var CertificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
 func init() {
 	err := scheme.AddToScheme(Scheme)
 	if err != nil {
@@ -144,6 +225,14 @@ func init() {
 		log.Error(err, "Failed to add the Prometheus scheme to scheme")
 		os.Exit(1)
 	}
+
+	err = apiextensionsv1.AddToScheme(Scheme)
+	if err != nil {
+		log.Error(err, "Failed to add the apiextensions scheme to scheme")
+		os.Exit(1)
+	}
+
+	Scheme.AddKnownTypeWithName(CertificateGVK, &unstructured.Unstructured{})
 }
 
 // NewRegistrationOption creates a new registration option for the addon.
@@ -152,7 +241,7 @@ func NewRegistrationOption(
 	controllerContext *controllercmd.ControllerContext,
 	addonName string,
 	agentPermissionFiles []string,
-	filesystem embed.FS,
+	filesystem fs.FS,
 	useClusterRole bool,
 ) *agent.RegistrationOption {
 	applyManifestFromFile := func(file, clusterName string,
@@ -178,7 +267,7 @@ func NewRegistrationOption(
 			recorder,
 			resourceapply.NewResourceCache(),
 			func(name string) ([]byte, error) {
-				template, err := filesystem.ReadFile(file)
+				template, err := fs.ReadFile(filesystem, file)
 				if err != nil {
 					return nil, err
 				}
@@ -224,56 +313,19 @@ func NewRegistrationOption(
 	}
 }
 
-// GetClusterVendor determines the vendor of the cluster based on the labels
-// and cluster claims.
-func GetClusterVendor(cluster *clusterv1.ManagedCluster) string {
-	var vendor string
-	// Don't just set it to the value in the label, it might be something like "auto-detect"
-	if cluster.Labels["vendor"] == "OpenShift" {
-		vendor = "OpenShift"
-	}
-
-	for _, cc := range cluster.Status.ClusterClaims {
-		if cc.Name == "product.open-cluster-management.io" {
-			vendor = cc.Value
-
-			break
-		}
-	}
-
-	return vendor
-}
-
-// GetAndAddAgent adds the agent to the manager.
-func GetAndAddAgent(
-	ctx context.Context,
-	mgr addonmanager.AddonManager,
-	addonName string,
-	controllerContext *controllercmd.ControllerContext,
-	getAgent func(context.Context, *controllercmd.ControllerContext) (agent.AgentAddon, error),
-) error {
-	agentAddon, err := getAgent(ctx, controllerContext)
-	if err != nil {
-		return fmt.Errorf("failed getting the %v agent addon: %w", addonName, err)
-	}
-
-	agentAddon = &PolicyAgentAddon{agentAddon}
-
-	err = mgr.AddAgent(agentAddon)
-	if err != nil {
-		return fmt.Errorf("failed adding the %v agent addon to the manager: %w", addonName, err)
-	}
-
-	return nil
-}
-
 // PolicyAgentAddon wraps the AgentAddon created from the addonfactory to override some behavior
 type PolicyAgentAddon struct {
 	agent.AgentAddon
+	// PostRenderFuncs transform the rendered manifests in order, after Helm rendering.
+	PostRenderFuncs []PostRenderFunc
+	// AddonClient patches the conditions set while rendering in the ManagedClusterAddOn status.
+	AddonClient addonv1alpha1client.Interface
 }
 
 // Manifests overrides the AgentAddon.Manifests method to return an error when
-// the policy addon is paused.
+// the policy addon is paused, and to apply the post-render functions. The conditions
+// set while rendering are patched in the ManagedClusterAddOn status afterwards, even
+// when the rendering fails.
 func (pa *PolicyAgentAddon) Manifests(
 	ctx context.Context,
 	cluster *clusterv1.ManagedCluster,
@@ -285,7 +337,32 @@ func (pa *PolicyAgentAddon) Manifests(
 		return nil, errors.New("the Policy Addon controller is paused due to the policy-addon-pause annotation")
 	}
 
-	return pa.AgentAddon.Manifests(ctx, cluster, addon)
+	if pa.AddonClient != nil {
+		var conditions *AddonConditions
+
+		ctx, conditions = WithAddonConditions(ctx)
+
+		defer func() {
+			if err := patchAddonConditions(ctx, pa.AddonClient, addon, conditions); err != nil {
+				log.Error(err, "failed to patch the addon status conditions",
+					"namespace", addon.Namespace, "name", addon.Name)
+			}
+		}()
+	}
+
+	objects, err := pa.AgentAddon.Manifests(ctx, cluster, addon)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, postRender := range pa.PostRenderFuncs {
+		objects, err = postRender(ctx, cluster, addon, objects)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return objects, nil
 }
 
 // CommonAgentInstallNamespaceFromDeploymentConfigFunc returns a function that
@@ -389,6 +466,19 @@ func (cv *CommonValues) SetClientBurst(value string) error {
 	return nil
 }
 
+// SetReplicas sets the number of replicas of the agent, between 1 and maxReplicas.
+func (cv *CommonValues) SetReplicas(value string) error {
+	replicas, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || replicas < 1 || replicas > maxReplicas {
+		return fmt.Errorf("invalid replicas value '%s', expected a number between 1 and %d", value, maxReplicas)
+	}
+
+	// This is safe because of the bounds check
+	cv.Replicas = int32(replicas)
+
+	return nil
+}
+
 // SetTLSMinVersion sets the addon's --tls-min-version flag.
 // Invalid values will be rejected with an error, and the flag will be unset.
 func (cv *CommonValues) SetTLSMinVersion(value string) error {
@@ -413,6 +503,173 @@ func (cv *CommonValues) SetTLSCipherSuites(value string) error {
 	return nil
 }
 
+// SetCommonLabels sets the labels added to every object deployed by the addon from a
+// comma-separated list of key=value pairs. Invalid values will be rejected with an error,
+// and the labels will be unset.
+func (cv *CommonValues) SetCommonLabels(value string) error {
+	labels, err := parseKeyValuePairs(value)
+	if err != nil {
+		return fmt.Errorf("failed to parse common labels '%s' (leaving unset): %w", value, err)
+	}
+
+	for key, val := range labels {
+		errs := append(validation.IsQualifiedName(key), validation.IsValidLabelValue(val)...)
+		if len(errs) > 0 {
+			return fmt.Errorf("invalid common label '%s=%s' (leaving unset): %v", key, val, errs)
+		}
+	}
+
+	if cv.GlobalValues == nil {
+		cv.GlobalValues = &GlobalValues{}
+	}
+
+	cv.GlobalValues.CommonLabels = labels
+
+	return nil
+}
+
+// SetCommonAnnotations sets the annotations added to every object deployed by the addon
+// from a comma-separated list of key=value pairs. Invalid values will be rejected with an
+// error, and the annotations will be unset.
+func (cv *CommonValues) SetCommonAnnotations(value string) error {
+	annotations, err := parseKeyValuePairs(value)
+	if err != nil {
+		return fmt.Errorf("failed to parse common annotations '%s' (leaving unset): %w", value, err)
+	}
+
+	for key := range annotations {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid common annotation key '%s' (leaving unset): %v", key, errs)
+		}
+	}
+
+	if cv.GlobalValues == nil {
+		cv.GlobalValues = &GlobalValues{}
+	}
+
+	cv.GlobalValues.CommonAnnotations = annotations
+
+	return nil
+}
+
+// parseKeyValuePairs parses a comma-separated list of key=value pairs.
+func parseKeyValuePairs(value string) (map[string]string, error) {
+	pairs := map[string]string{}
+
+	for pair := range strings.SplitSeq(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected key=value, got '%s'", pair)
+		}
+
+		pairs[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+
+	return pairs, nil
+}
+
+// networkPolicies returns the network policies values, initializing them if needed.
+func (cv *CommonValues) networkPolicies() *NetworkPolicies {
+	if cv.GlobalValues == nil {
+		cv.GlobalValues = &GlobalValues{}
+	}
+
+	if cv.GlobalValues.NetworkPolicies == nil {
+		cv.GlobalValues.NetworkPolicies = &NetworkPolicies{Enabled: GetNetworkPoliciesEnabled()}
+	}
+
+	return cv.GlobalValues.NetworkPolicies
+}
+
+// SetNetworkPoliciesEnabled sets whether the network policies are deployed for the cluster,
+// overriding the NETWORK_POLICIES_ENABLED environment variable.
+func (cv *CommonValues) SetNetworkPoliciesEnabled(value string) error {
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("failed to parse network policies enabled boolean '%s' (leaving unset): %w", value, err)
+	}
+
+	cv.networkPolicies().Enabled = enabled
+
+	return nil
+}
+
+// SetNetworkPoliciesEgressPorts sets the additional egress ports allowed by the network
+// policies from a comma-separated list of ports, each optionally followed by /TCP, /UDP, or
+// /SCTP. The protocol defaults to TCP. Invalid values will be rejected with an error.
+func (cv *CommonValues) SetNetworkPoliciesEgressPorts(value string) error {
+	ports := []NetworkPolicyPort{}
+
+	for entry := range strings.SplitSeq(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		portStr, protocol, _ := strings.Cut(entry, "/")
+
+		port, err := strconv.ParseInt(portStr, 10, 32)
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("invalid network policy egress port '%s' (leaving unset)", entry)
+		}
+
+		switch corev1.Protocol(strings.ToUpper(protocol)) {
+		case "", corev1.ProtocolTCP:
+			protocol = string(corev1.ProtocolTCP)
+		case corev1.ProtocolUDP, corev1.ProtocolSCTP:
+			protocol = strings.ToUpper(protocol)
+		default:
+			return fmt.Errorf("invalid network policy egress port protocol '%s' (leaving unset)", entry)
+		}
+
+		ports = append(ports, NetworkPolicyPort{Port: int32(port), Protocol: corev1.Protocol(protocol)})
+	}
+
+	cv.networkPolicies().EgressPorts = ports
+
+	return nil
+}
+
+// SetNetworkPoliciesEgressCIDRs sets the additional egress destinations allowed by the network
+// policies from a comma-separated list of CIDRs. Invalid values will be rejected with an error.
+func (cv *CommonValues) SetNetworkPoliciesEgressCIDRs(value string) error {
+	cidrs := []string{}
+
+	for cidr := range strings.SplitSeq(value, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid network policy egress CIDR '%s' (leaving unset): %w", cidr, err)
+		}
+
+		cidrs = append(cidrs, cidr)
+	}
+
+	cv.networkPolicies().EgressCIDRs = cidrs
+
+	return nil
+}
+
+// SetNetworkPoliciesMetricsNamespace restricts the metrics ingress allowed by the network
+// policies to the namespace. Invalid values will be rejected with an error.
+func (cv *CommonValues) SetNetworkPoliciesMetricsNamespace(value string) error {
+	if errs := validation.IsDNS1123Label(value); len(errs) > 0 {
+		return fmt.Errorf("invalid network policy metrics namespace '%s' (leaving unset): %v", value, errs)
+	}
+
+	cv.networkPolicies().MetricsNamespace = value
+
+	return nil
+}
+
 // SetPrometheusEnabled sets the Prometheus metrics enabled boolean for the
 // addon chart, enabling metrics configurations to be deployed.
 func (cv *CommonValues) SetPrometheusEnabled(value string) error {
@@ -422,45 +679,200 @@ func (cv *CommonValues) SetPrometheusEnabled(value string) error {
 			value, false, err)
 	}
 
-	cv.PrometheusConfig = &PrometheusConfig{
-		Enabled: prometheusEnabled,
+	cv.prometheusConfig().Enabled = &prometheusEnabled
+
+	return nil
+}
+
+// prometheusConfig returns the Prometheus values, initializing them if needed.
+func (cv *CommonValues) prometheusConfig() *PrometheusConfig {
+	if cv.PrometheusConfig == nil {
+		cv.PrometheusConfig = &PrometheusConfig{}
+	}
+
+	return cv.PrometheusConfig
+}
+
+// serviceMonitor returns the ServiceMonitor values, initializing them if needed.
+func (cv *CommonValues) serviceMonitor() *ServiceMonitor {
+	if cv.prometheusConfig().ServiceMonitor == nil {
+		cv.PrometheusConfig.ServiceMonitor = &ServiceMonitor{}
+	}
+
+	return cv.PrometheusConfig.ServiceMonitor
+}
+
+// SetServiceMonitorNamespace sets the namespace of the ServiceMonitor for the addon, for
+// Prometheus instances only selecting ServiceMonitors in their own namespace.
+func (cv *CommonValues) SetServiceMonitorNamespace(value string) error {
+	if errs := validation.IsDNS1123Label(value); len(errs) > 0 {
+		return fmt.Errorf("invalid ServiceMonitor namespace '%s' (falling back to the addon namespace): %v",
+			value, errs)
+	}
+
+	cv.serviceMonitor().Namespace = &value
+
+	return nil
+}
+
+// SetServiceMonitorInterval sets the interval at which Prometheus scrapes the metrics of the
+// addon, like "1m". Invalid values will be rejected with an error.
+func (cv *CommonValues) SetServiceMonitorInterval(value string) error {
+	interval, err := model.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		return fmt.Errorf("invalid ServiceMonitor interval '%s' (falling back to default value 30s)", value)
+	}
+
+	cv.serviceMonitor().Interval = value
+
+	return nil
+}
+
+// SetServiceMonitorLabels sets the labels added to the ServiceMonitor from a comma-separated
+// list of key=value pairs, for Prometheus instances selecting ServiceMonitors by label.
+func (cv *CommonValues) SetServiceMonitorLabels(value string) error {
+	labels, err := parseKeyValuePairs(value)
+	if err != nil {
+		return fmt.Errorf("failed to parse ServiceMonitor labels '%s' (leaving unset): %w", value, err)
+	}
+
+	for key, val := range labels {
+		errs := append(validation.IsQualifiedName(key), validation.IsValidLabelValue(val)...)
+		if len(errs) > 0 {
+			return fmt.Errorf("invalid ServiceMonitor label '%s=%s' (leaving unset): %v", key, val, errs)
+		}
+	}
+
+	cv.serviceMonitor().Labels = labels
+
+	return nil
+}
+
+// metricsTLS returns the metrics TLS values, initializing them if needed.
+func (cv *CommonValues) metricsTLS() *MetricsTLS {
+	if cv.prometheusConfig().TLS == nil {
+		cv.PrometheusConfig.TLS = &MetricsTLS{}
+	}
+
+	return cv.PrometheusConfig.TLS
+}
+
+// SetMetricsCertSecret sets the secret, in the namespace of the addon, with the metrics serving
+// certificate in the tls.crt, tls.key and ca.crt keys. It enables secure metrics on any
+// distribution and takes precedence over the cert-manager issuer.
+func (cv *CommonValues) SetMetricsCertSecret(value string) error {
+	if errs := validation.IsDNS1123Subdomain(value); len(errs) > 0 {
+		return fmt.Errorf("invalid metrics certificate secret name '%s' (leaving unset): %v", value, errs)
+	}
+
+	cv.metricsTLS().SecretName = value
+
+	return nil
+}
+
+// SetMetricsCertIssuer sets the cert-manager issuer, as [<kind>/]<name> with the kind defaulting
+// to Issuer, of the metrics serving certificate. It enables secure metrics on any distribution.
+func (cv *CommonValues) SetMetricsCertIssuer(value string) error {
+	kind, name, found := strings.Cut(value, "/")
+	if !found {
+		kind, name = "", value
+	}
+
+	if found && kind != "Issuer" && kind != "ClusterIssuer" {
+		return fmt.Errorf("invalid metrics certificate issuer kind '%s', expected Issuer or ClusterIssuer "+
+			"(leaving unset)", kind)
+	}
+
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("invalid metrics certificate issuer name '%s' (leaving unset): %v", name, errs)
 	}
 
+	cv.metricsTLS().Issuer = &CertificateIssuer{Name: name, Kind: kind}
+
+	return nil
+}
+
+// SetPrometheusRuleEnabled sets whether the PrometheusRule with the alerts for the addon is
+// deployed along with the ServiceMonitor.
+func (cv *CommonValues) SetPrometheusRuleEnabled(value string) error {
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("failed to parse PrometheusRule enabled boolean '%s' (falling back to default value %t): %w",
+			value, false, err)
+	}
+
+	cv.prometheusConfig().PrometheusRule = &PrometheusRule{Enabled: enabled}
+
 	return nil
 }
 
 // SetCommonValues populates settings in the common chart values for the addon
-// based on the environment. It returns an error for the respective component
-// addon handler.
+// based on the environment, like the distribution profile and the footprint of
+// the cluster where the agents run. It returns an error for the respective
+// component addon handler.
 //
-// Currently the only error is a fetch error for the hosting cluster, which
-// would warrant a retry.
+// Currently the only error is a fetch error for the AddOnDeploymentConfig,
+// which would warrant a retry.
 func (cv *CommonValues) SetCommonValues(
 	cluster *clusterv1.ManagedCluster,
 	addon *addonapiv1beta1.ManagedClusterAddOn,
-	clusterClient clusterlistersv1.ManagedClusterLister,
+	clients *HubClients,
 ) error {
-	var err error
 	// Set the Kubernetes distribution for the current cluster
 	cv.KubernetesDistribution = GetClusterVendor(cluster)
 
 	// Set the Kubernetes distribution for the hosting cluster
+	cv.HostingKubernetesDistribution = getHostingClusterVendor(cluster, addon, clients.ClusterLister)
+
+	profile := GetDistributionProfile(cv.HostingKubernetesDistribution)
+	cv.SetDistributionProfile(profile)
+
+	footprint, err := getFootprint(clients, addon, profile)
+	if err != nil {
+		return err
+	}
+
+	if footprint == FootprintEdge {
+		cv.SetEdgeFootprint()
+	}
+
+	return nil
+}
+
+// getHostingClusterVendor returns the vendor of the cluster where the agents run, which is the
+// hosting cluster in hosted mode. It is empty when the hosting cluster isn't found.
+func getHostingClusterVendor(
+	cluster *clusterv1.ManagedCluster,
+	addon *addonapiv1beta1.ManagedClusterAddOn,
+	clusterClient clusterlistersv1.ManagedClusterLister,
+) string {
 	hostingClusterName := addon.GetAnnotations()[addonapiv1beta1.HostingClusterNameAnnotationKey]
-	if hostingClusterName != "" {
-		hostingCluster, err := clusterClient.Get(hostingClusterName)
-		if err == nil {
-			cv.HostingKubernetesDistribution = GetClusterVendor(hostingCluster)
-		}
-	} else {
-		cv.HostingKubernetesDistribution = cv.KubernetesDistribution
+	if hostingClusterName == "" {
+		return GetClusterVendor(cluster)
 	}
 
-	// Enable Prometheus metrics by default on OpenShift
+	hostingCluster, err := clusterClient.Get(hostingClusterName)
+	if err != nil {
+		return ""
+	}
+
+	return GetClusterVendor(hostingCluster)
+}
+
+// SetDistributionProfile sets the default values from the profile of the distribution of the
+// hosting cluster, where the agents run. Prometheus metrics are only enabled by default on
+// OpenShift.
+func (cv *CommonValues) SetDistributionProfile(profile DistributionProfile) {
 	cv.PrometheusConfig = &PrometheusConfig{
-		Enabled: cv.HostingKubernetesDistribution == "OpenShift",
+		Enabled: &profile.PrometheusEnabled,
 	}
 
-	return err
+	if len(profile.Tolerations) > 0 || profile.SeccompProfileType != "" {
+		cv.DistributionProfile = &DistributionProfileValues{
+			Tolerations:        profile.Tolerations,
+			SeccompProfileType: profile.SeccompProfileType,
+		}
+	}
 }
 
 // SetCommonValuesFromCustomizedVariables sets the common values for the addon
@@ -475,14 +887,34 @@ func (cv *CommonValues) SetCommonValuesFromCustomizedVariables(
 
 	//nolint:nlreturn,unparam
 	variableToFuncMap := map[string]func(string) error{
-		"logLevel":              cv.SetLogLevel,
-		"logEncoder":            func(value string) error { cv.LogEncoder = value; return nil },
-		"evaluationConcurrency": cv.SetEvaluationConcurrency,
-		"clientQPS":             cv.SetClientQPS,
-		"clientBurst":           cv.SetClientBurst,
-		"prometheusEnabled":     cv.SetPrometheusEnabled,
-		"tlsMinVersion":         cv.SetTLSMinVersion,
-		"tlsCipherSuites":       cv.SetTLSCipherSuites,
+		"logLevel":                        cv.SetLogLevel,
+		"logEncoder":                      func(value string) error { cv.LogEncoder = value; return nil },
+		"evaluationConcurrency":           cv.SetEvaluationConcurrency,
+		"clientQPS":                       cv.SetClientQPS,
+		"clientBurst":                     cv.SetClientBurst,
+		"replicas":                        cv.SetReplicas,
+		"prometheusEnabled":               cv.SetPrometheusEnabled,
+		"serviceMonitorNamespace":         cv.SetServiceMonitorNamespace,
+		"serviceMonitorInterval":          cv.SetServiceMonitorInterval,
+		"serviceMonitorLabels":            cv.SetServiceMonitorLabels,
+		"prometheusRuleEnabled":           cv.SetPrometheusRuleEnabled,
+		"metricsCertSecret":               cv.SetMetricsCertSecret,
+		"metricsCertIssuer":               cv.SetMetricsCertIssuer,
+		"tlsMinVersion":                   cv.SetTLSMinVersion,
+		"tlsCipherSuites":                 cv.SetTLSCipherSuites,
+		"commonLabels":                    cv.SetCommonLabels,
+		"commonAnnotations":               cv.SetCommonAnnotations,
+		"networkPoliciesEnabled":          cv.SetNetworkPoliciesEnabled,
+		"networkPoliciesEgressPorts":      cv.SetNetworkPoliciesEgressPorts,
+		"networkPoliciesEgressCIDRs":      cv.SetNetworkPoliciesEgressCIDRs,
+		"networkPoliciesMetricsNamespace": cv.SetNetworkPoliciesMetricsNamespace,
+		// Handled by the post-render stage rather than the chart
+		PostRenderPatchesVariable: func(string) error { return nil },
+		ExtraArgsVariable:         func(string) error { return nil },
+		ExtraEnvVariable:          func(string) error { return nil },
+		GoMaxProcsVariable:        func(string) error { return nil },
+		// Handled by SetCommonValues, so that the other variables override the footprint
+		FootprintVariable: func(string) error { return nil },
 	}
 
 	for _, variable := range config.Spec.CustomizedVariables {
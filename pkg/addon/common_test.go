@@ -56,3 +56,233 @@ func TestSetTLSCipherSuites(t *testing.T) {
 		}
 	})
 }
+
+func TestSetReplicas(t *testing.T) {
+	cv := &CommonValues{}
+
+	if err := cv.SetReplicas("3"); err != nil || cv.Replicas != 3 {
+		t.Fatalf("expected the replicas to be set, got: %d, %v", cv.Replicas, err)
+	}
+
+	for _, value := range []string{"0", "6", "two"} {
+		if err := cv.SetReplicas(value); err == nil {
+			t.Fatalf("expected an error for the replicas value %q", value)
+		}
+	}
+
+	if cv.Replicas != 3 {
+		t.Fatalf("expected the invalid replicas to be ignored, got: %d", cv.Replicas)
+	}
+}
+
+func TestSetCommonLabels(t *testing.T) {
+	t.Run("valid labels are set", func(t *testing.T) {
+		cv := &CommonValues{}
+
+		if err := cv.SetCommonLabels("example.com/team=governance, cost-center=1234"); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		labels := cv.GlobalValues.CommonLabels
+		if len(labels) != 2 || labels["example.com/team"] != "governance" || labels["cost-center"] != "1234" {
+			t.Fatalf("expected the labels to be set, got: %v", labels)
+		}
+	})
+
+	t.Run("invalid labels are rejected and left unset", func(t *testing.T) {
+		for _, value := range []string{"team", "team=not valid", "-team=governance"} {
+			cv := &CommonValues{}
+
+			if err := cv.SetCommonLabels(value); err == nil {
+				t.Fatalf("expected an error for the labels %q", value)
+			}
+
+			if cv.GlobalValues != nil {
+				t.Fatalf("expected the labels to be left unset, got: %v", cv.GlobalValues.CommonLabels)
+			}
+		}
+	})
+}
+
+func TestSetCommonAnnotations(t *testing.T) {
+	cv := &CommonValues{}
+
+	if err := cv.SetCommonAnnotations("example.com/backup=true,description=not a label value"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if cv.GlobalValues.CommonAnnotations["description"] != "not a label value" {
+		t.Fatalf("expected the annotations to be set, got: %v", cv.GlobalValues.CommonAnnotations)
+	}
+
+	if err := cv.SetCommonAnnotations("not/a/key=value"); err == nil {
+		t.Fatal("expected an error for an invalid annotation key")
+	}
+}
+
+func TestSetNetworkPolicies(t *testing.T) {
+	t.Run("valid values are set", func(t *testing.T) {
+		t.Setenv(NetworkPoliciesEnabledEnvVar, "true")
+
+		cv := &CommonValues{}
+
+		if err := cv.SetNetworkPoliciesEnabled("false"); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if err := cv.SetNetworkPoliciesEgressPorts("8444, 53/udp"); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if err := cv.SetNetworkPoliciesEgressCIDRs("10.0.0.0/8,fd00::/8"); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if err := cv.SetNetworkPoliciesMetricsNamespace("openshift-monitoring"); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		np := cv.GlobalValues.NetworkPolicies
+		if np.Enabled {
+			t.Fatal("expected the network policies to be disabled")
+		}
+
+		if len(np.EgressPorts) != 2 || np.EgressPorts[0] != (NetworkPolicyPort{8444, "TCP"}) ||
+			np.EgressPorts[1] != (NetworkPolicyPort{53, "UDP"}) {
+			t.Fatalf("expected the egress ports to be set, got: %v", np.EgressPorts)
+		}
+
+		if len(np.EgressCIDRs) != 2 || np.MetricsNamespace != "openshift-monitoring" {
+			t.Fatalf("expected the egress CIDRs and metrics namespace to be set, got: %+v", np)
+		}
+	})
+
+	t.Run("invalid values are rejected", func(t *testing.T) {
+		cv := &CommonValues{}
+
+		if err := cv.SetNetworkPoliciesEnabled("maybe"); err == nil {
+			t.Fatal("expected an error for an invalid boolean")
+		}
+
+		for _, ports := range []string{"0", "65536", "http", "443/ICMP"} {
+			if err := cv.SetNetworkPoliciesEgressPorts(ports); err == nil {
+				t.Fatalf("expected an error for the egress ports %q", ports)
+			}
+		}
+
+		if err := cv.SetNetworkPoliciesEgressCIDRs("10.0.0.0"); err == nil {
+			t.Fatal("expected an error for an invalid CIDR")
+		}
+
+		if err := cv.SetNetworkPoliciesMetricsNamespace("Monitoring"); err == nil {
+			t.Fatal("expected an error for an invalid namespace")
+		}
+	})
+}
+
+func TestSetPrometheusConfig(t *testing.T) {
+	t.Run("valid values are set", func(t *testing.T) {
+		cv := &CommonValues{}
+
+		if err := cv.SetServiceMonitorNamespace("monitoring"); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if err := cv.SetServiceMonitorInterval("1m30s"); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if err := cv.SetServiceMonitorLabels("release=prometheus, team=policy"); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if err := cv.SetPrometheusRuleEnabled("true"); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		// Enabling the metrics must keep the ServiceMonitor settings
+		if err := cv.SetPrometheusEnabled("true"); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		prom := cv.PrometheusConfig
+		if prom.Enabled == nil || !*prom.Enabled || prom.PrometheusRule == nil || !prom.PrometheusRule.Enabled {
+			t.Fatalf("expected the metrics and the PrometheusRule to be enabled, got: %+v", prom)
+		}
+
+		sm := prom.ServiceMonitor
+		if sm == nil || sm.Namespace == nil || *sm.Namespace != "monitoring" || sm.Interval != "1m30s" {
+			t.Fatalf("expected the ServiceMonitor namespace and interval to be set, got: %+v", sm)
+		}
+
+		if len(sm.Labels) != 2 || sm.Labels["release"] != "prometheus" || sm.Labels["team"] != "policy" {
+			t.Fatalf("expected the ServiceMonitor labels to be set, got: %v", sm.Labels)
+		}
+	})
+
+	t.Run("invalid values are rejected", func(t *testing.T) {
+		cv := &CommonValues{}
+
+		if err := cv.SetServiceMonitorNamespace("Monitoring"); err == nil {
+			t.Fatal("expected an error for an invalid namespace")
+		}
+
+		for _, interval := range []string{"30", "0s", "-1m", "fast"} {
+			if err := cv.SetServiceMonitorInterval(interval); err == nil {
+				t.Fatalf("expected an error for the interval %q", interval)
+			}
+		}
+
+		if err := cv.SetServiceMonitorLabels("release"); err == nil {
+			t.Fatal("expected an error for a label without a value")
+		}
+
+		if err := cv.SetPrometheusRuleEnabled("maybe"); err == nil {
+			t.Fatal("expected an error for an invalid boolean")
+		}
+
+		if cv.PrometheusConfig != nil && cv.PrometheusConfig.ServiceMonitor != nil &&
+			(cv.PrometheusConfig.ServiceMonitor.Namespace != nil || cv.PrometheusConfig.ServiceMonitor.Interval != "") {
+			t.Fatalf("expected the ServiceMonitor to be unchanged, got: %+v", cv.PrometheusConfig.ServiceMonitor)
+		}
+	})
+}
+
+func TestSetMetricsTLS(t *testing.T) {
+	cv := &CommonValues{}
+
+	if err := cv.SetMetricsCertSecret("metrics-cert"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if err := cv.SetMetricsCertIssuer("ClusterIssuer/my-ca"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	tls := cv.PrometheusConfig.TLS
+	if tls.SecretName != "metrics-cert" || *tls.Issuer != (CertificateIssuer{Name: "my-ca", Kind: "ClusterIssuer"}) {
+		t.Fatalf("expected the secret and issuer to be set, got: %+v", tls)
+	}
+
+	if err := cv.SetMetricsCertIssuer("my-issuer"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if *tls.Issuer != (CertificateIssuer{Name: "my-issuer"}) {
+		t.Fatalf("expected the issuer kind to be left to the chart default, got: %+v", tls.Issuer)
+	}
+
+	for _, issuer := range []string{"Certificate/my-ca", "ClusterIssuer/My_CA", ""} {
+		if err := cv.SetMetricsCertIssuer(issuer); err == nil {
+			t.Fatalf("expected an error for the issuer %q", issuer)
+		}
+	}
+
+	if err := cv.SetMetricsCertSecret("Metrics_Cert"); err == nil {
+		t.Fatal("expected an error for an invalid secret name")
+	}
+
+	if _, err := Scheme.New(CertificateGVK); err != nil {
+		t.Fatalf("expected the Certificate kind to be in the scheme, got: %v", err)
+	}
+}
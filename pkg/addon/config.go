@@ -0,0 +1,254 @@
+package addon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// controllerConfigPollInterval is how often the controller configuration file is read again.
+// Mounted ConfigMaps are updated by the kubelet, so the file is polled rather than watched.
+const controllerConfigPollInterval = 10 * time.Second
+
+// ControllerConfig is the controller configuration file. Its settings take precedence over the
+// environment variables of the controller, and it is reloaded without a restart.
+type ControllerConfig struct {
+	// Images maps the image keys of the addons, like config_policy_controller, to the images of
+	// the agents. They take precedence over the image environment variables.
+	Images map[string]string `json:"images,omitempty"`
+	// NetworkPoliciesEnabled takes precedence over the NETWORK_POLICIES_ENABLED environment variable.
+	NetworkPoliciesEnabled *bool `json:"networkPoliciesEnabled,omitempty"`
+	// CustomizedVariables are the defaults of the customized variables for every managed cluster,
+	// which the AddOnDeploymentConfig overrides.
+	CustomizedVariables map[string]string `json:"customizedVariables,omitempty"`
+}
+
+// controllerConfig is the current controller configuration. It is replaced as a whole by
+// SetControllerConfig, so that the renders running concurrently with a reload each see either
+// the previous or the new configuration.
+var controllerConfig atomic.Pointer[ControllerConfig]
+
+// GetControllerConfig returns the current controller configuration, which is empty when there
+// is no configuration file. It is shared by the renders and must not be modified.
+func GetControllerConfig() *ControllerConfig {
+	if config := controllerConfig.Load(); config != nil {
+		return config
+	}
+
+	return &ControllerConfig{}
+}
+
+// SetControllerConfig replaces the current controller configuration.
+func SetControllerConfig(config *ControllerConfig) {
+	controllerConfig.Store(config)
+}
+
+// LoadControllerConfig reads and validates the controller configuration file. The images must be
+// for one of the image keys of the addons.
+func LoadControllerConfig(path string, imageKeys []string) (*ControllerConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the controller configuration file %s: %w", path, err)
+	}
+
+	return parseControllerConfig(content, imageKeys)
+}
+
+func parseControllerConfig(content []byte, imageKeys []string) (*ControllerConfig, error) {
+	config := &ControllerConfig{}
+
+	if err := yaml.UnmarshalStrict(content, config); err != nil {
+		return nil, fmt.Errorf("failed to parse the controller configuration: %w", err)
+	}
+
+	var aggregateErr error
+
+	for key, img := range config.Images {
+		if !slices.Contains(imageKeys, key) {
+			aggregateErr = errors.Join(aggregateErr, fmt.Errorf("unknown image key '%s', expected one of %v",
+				key, imageKeys))
+		}
+
+		if img == "" || strings.ContainsAny(img, " \t\n") {
+			aggregateErr = errors.Join(aggregateErr, fmt.Errorf("invalid image '%s' for the image key '%s'", img, key))
+		}
+	}
+
+	if footprint, ok := config.CustomizedVariables[FootprintVariable]; ok &&
+		footprint != FootprintStandard && footprint != FootprintEdge {
+		aggregateErr = errors.Join(aggregateErr, fmt.Errorf("invalid footprint '%s', expected %s or %s",
+			footprint, FootprintStandard, FootprintEdge))
+	}
+
+	if _, ok := config.CustomizedVariables[PostRenderPatchesVariable]; ok {
+		aggregateErr = errors.Join(aggregateErr, fmt.Errorf("the %s customized variable can't have a default, "+
+			"use the %s annotation of the ClusterManagementAddOn instead", PostRenderPatchesVariable,
+			PostRenderPatchesAnnotation))
+	}
+
+	// The addon-specific variables are returned as unknown, and are validated when rendering
+	cv := &CommonValues{}
+
+	if _, err := cv.SetCommonValuesFromCustomizedVariables(config.defaultsAsDeploymentConfig()); err != nil {
+		aggregateErr = errors.Join(aggregateErr, err)
+	}
+
+	if aggregateErr != nil {
+		return nil, fmt.Errorf("invalid controller configuration: %w", aggregateErr)
+	}
+
+	return config, nil
+}
+
+// defaultsAsDeploymentConfig returns an AddOnDeploymentConfig with the default customized
+// variables, so that they are converted to values like the ones of the managed clusters.
+func (c *ControllerConfig) defaultsAsDeploymentConfig() addonapiv1beta1.AddOnDeploymentConfig {
+	config := addonapiv1beta1.AddOnDeploymentConfig{}
+
+	names := make([]string, 0, len(c.CustomizedVariables))
+	for name := range c.CustomizedVariables {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	for _, name := range names {
+		config.Spec.CustomizedVariables = append(config.Spec.CustomizedVariables,
+			addonapiv1beta1.CustomizedVariable{Name: name, Value: c.CustomizedVariables[name]})
+	}
+
+	return config
+}
+
+// WatchControllerConfig reads the controller configuration file periodically until the context
+// is canceled. When it is valid and differs from the current configuration, it replaces it and
+// onChange is called to render the addons again. An invalid configuration is logged and the
+// current one is kept.
+func WatchControllerConfig(ctx context.Context, path string, imageKeys []string, onChange func()) {
+	// The first read compares the file with the current configuration, in case it changed since
+	// it was loaded
+	var lastContent []byte
+
+	wait.UntilWithContext(ctx, func(_ context.Context) {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			log.Error(err, "failed to read the controller configuration file, keeping the current configuration",
+				"path", path)
+
+			return
+		}
+
+		if bytes.Equal(content, lastContent) {
+			return
+		}
+
+		lastContent = content
+
+		config, err := parseControllerConfig(content, imageKeys)
+		if err != nil {
+			log.Error(err, "the controller configuration file is invalid, keeping the current configuration",
+				"path", path)
+
+			return
+		}
+
+		if equality.Semantic.DeepEqual(config, GetControllerConfig()) {
+			return
+		}
+
+		SetControllerConfig(config)
+
+		log.Info("Reloaded the controller configuration file, rendering the addons again", "path", path)
+
+		onChange()
+	}, controllerConfigPollInterval)
+}
+
+// withControllerConfigDefaults returns a copy of the AddOnDeploymentConfig with the default
+// customized variables of the controller configuration that it doesn't set. It returns nil when
+// there is neither an AddOnDeploymentConfig nor default customized variables.
+func withControllerConfigDefaults(
+	config *addonapiv1beta1.AddOnDeploymentConfig,
+) *addonapiv1beta1.AddOnDeploymentConfig {
+	defaults := GetControllerConfig().defaultsAsDeploymentConfig()
+
+	if config == nil {
+		if len(defaults.Spec.CustomizedVariables) == 0 {
+			return nil
+		}
+
+		return &defaults
+	}
+
+	config = config.DeepCopy()
+
+	for _, variable := range defaults.Spec.CustomizedVariables {
+		if !slices.ContainsFunc(config.Spec.CustomizedVariables, func(v addonapiv1beta1.CustomizedVariable) bool {
+			return v.Name == variable.Name
+		}) {
+			config.Spec.CustomizedVariables = append(config.Spec.CustomizedVariables, variable)
+		}
+	}
+
+	return config
+}
+
+// GetCustomizedVariables returns the customized variables of the AddOnDeploymentConfig of the
+// addon, with the defaults of the controller configuration.
+func GetCustomizedVariables(
+	adcGetter utils.AddOnDeploymentConfigGetter, addon *addonapiv1beta1.ManagedClusterAddOn,
+) (map[string]string, error) {
+	config, err := utils.GetDesiredAddOnDeploymentConfig(addon, adcGetter)
+	if err != nil {
+		return nil, err
+	}
+
+	variables := map[string]string{}
+
+	if config = withControllerConfigDefaults(config); config != nil {
+		for _, variable := range config.Spec.CustomizedVariables {
+			variables[variable.Name] = variable.Value
+		}
+	}
+
+	return variables, nil
+}
+
+// getCustomizedVariableValues returns the values of the customized variables of the
+// AddOnDeploymentConfig, with the defaults of the controller configuration, converted by the
+// addon's customizedVariableValues function.
+func getCustomizedVariableValues(
+	adcGetter utils.AddOnDeploymentConfigGetter,
+	customizedVariableValues addonfactory.AddOnDeploymentConfigToValuesFunc,
+) addonfactory.GetValuesFunc {
+	return func(
+		_ *clusterv1.ManagedCluster,
+		addon *addonapiv1beta1.ManagedClusterAddOn,
+	) (addonfactory.Values, error) {
+		config, err := utils.GetDesiredAddOnDeploymentConfig(addon, adcGetter)
+		if err != nil {
+			return nil, err
+		}
+
+		config = withControllerConfigDefaults(config)
+		if config == nil {
+			return addonfactory.Values{}, nil
+		}
+
+		return customizedVariableValues(*config)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+)
+
+func TestLoadControllerConfig(t *testing.T) {
+	imageKeys := []string{"config_policy_controller", "governance_policy_framework_addon"}
+
+	tests := map[string]struct {
+		content     string
+		expectedErr string
+	}{
+		"valid": {
+			content: "images:\n  config_policy_controller: quay.io/ocm/config-policy-controller:v1\n" +
+				"networkPoliciesEnabled: false\n" +
+				"customizedVariables:\n  logLevel: \"2\"\n  footprint: edge\n  operatorPolicyDisabled: \"true\"\n",
+		},
+		"unknown field": {
+			content:     "image: quay.io/ocm/config-policy-controller:v1\n",
+			expectedErr: `unknown field "image"`,
+		},
+		"unknown image key": {
+			content:     "images:\n  my_controller: quay.io/ocm/my-controller:v1\n",
+			expectedErr: "unknown image key 'my_controller'",
+		},
+		"invalid customized variable": {
+			content:     "customizedVariables:\n  clientQPS: fast\n",
+			expectedErr: "failed to parse client QPS",
+		},
+		"invalid footprint": {
+			content:     "customizedVariables:\n  footprint: tiny\n",
+			expectedErr: "invalid footprint 'tiny'",
+		},
+		"post-render patches": {
+			content:     "customizedVariables:\n  postRenderPatches: my-patches\n",
+			expectedErr: "can't have a default",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+
+			if err := os.WriteFile(path, []byte(test.content), 0o600); err != nil {
+				t.Fatalf("failed to write the configuration file: %v", err)
+			}
+
+			config, err := LoadControllerConfig(path, imageKeys)
+			if test.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected an error containing %q, got: %v", test.expectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if config.NetworkPoliciesEnabled == nil || *config.NetworkPoliciesEnabled ||
+				config.Images["config_policy_controller"] != "quay.io/ocm/config-policy-controller:v1" ||
+				config.CustomizedVariables["operatorPolicyDisabled"] != "true" {
+				t.Fatalf("unexpected configuration: %+v", config)
+			}
+		})
+	}
+}
+
+func TestWithControllerConfigDefaults(t *testing.T) {
+	t.Cleanup(func() { SetControllerConfig(&ControllerConfig{}) })
+
+	if config := withControllerConfigDefaults(nil); config != nil {
+		t.Fatalf("expected no AddOnDeploymentConfig without defaults, got: %+v", config)
+	}
+
+	SetControllerConfig(&ControllerConfig{
+		CustomizedVariables: map[string]string{"logLevel": "2", "clientQPS": "50"},
+	})
+
+	if config := withControllerConfigDefaults(nil); len(config.Spec.CustomizedVariables) != 2 {
+		t.Fatalf("expected the defaults without an AddOnDeploymentConfig, got: %+v", config)
+	}
+
+	adc := &addonapiv1beta1.AddOnDeploymentConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "cluster1"},
+		Spec: addonapiv1beta1.AddOnDeploymentConfigSpec{
+			CustomizedVariables: []addonapiv1beta1.CustomizedVariable{{Name: "logLevel", Value: "4"}},
+		},
+	}
+
+	config := withControllerConfigDefaults(adc)
+
+	expected := []addonapiv1beta1.CustomizedVariable{{Name: "logLevel", Value: "4"}, {Name: "clientQPS", Value: "50"}}
+	if len(config.Spec.CustomizedVariables) != 2 || config.Spec.CustomizedVariables[0] != expected[0] ||
+		config.Spec.CustomizedVariables[1] != expected[1] {
+		t.Fatalf("expected the AddOnDeploymentConfig to override the defaults, got: %+v",
+			config.Spec.CustomizedVariables)
+	}
+
+	if len(adc.Spec.CustomizedVariables) != 1 {
+		t.Fatal("expected the AddOnDeploymentConfig to not be modified")
+	}
+}
+
+func TestGetNetworkPoliciesEnabledFromControllerConfig(t *testing.T) {
+	t.Setenv(NetworkPoliciesEnabledEnvVar, "true")
+	t.Cleanup(func() { SetControllerConfig(&ControllerConfig{}) })
+
+	disabled := false
+	SetControllerConfig(&ControllerConfig{NetworkPoliciesEnabled: &disabled})
+
+	if GetNetworkPoliciesEnabled() {
+		t.Fatal("expected the controller configuration to take precedence over the environment variable")
+	}
+}
+
+func TestWatchControllerConfig(t *testing.T) {
+	t.Cleanup(func() { SetControllerConfig(&ControllerConfig{}) })
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	if err := os.WriteFile(path, []byte("images:\n  config_policy_controller: example.com/cpc:v1\n"), 0o600); err != nil {
+		t.Fatalf("expected the configuration file to be written, got: %v", err)
+	}
+
+	watch := func() int {
+		ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+		defer cancel()
+
+		changes := 0
+
+		WatchControllerConfig(ctx, path, []string{"config_policy_controller"}, func() { changes++ })
+
+		return changes
+	}
+
+	// The file changed since the configuration was loaded
+	if changes := watch(); changes != 1 {
+		t.Fatalf("expected the addons to be rendered again once, got: %d", changes)
+	}
+
+	if img := GetControllerConfig().Images["config_policy_controller"]; img != "example.com/cpc:v1" {
+		t.Fatalf("expected the reloaded image, got: %s", img)
+	}
+
+	if changes := watch(); changes != 0 {
+		t.Fatalf("expected no render when the file matches the current configuration, got: %d", changes)
+	}
+
+	if err := os.WriteFile(path, []byte("images: [invalid"), 0o600); err != nil {
+		t.Fatalf("expected the configuration file to be written, got: %v", err)
+	}
+
+	if changes := watch(); changes != 0 {
+		t.Fatalf("expected no render for an invalid file, got: %d", changes)
+	}
+
+	if img := GetControllerConfig().Images["config_policy_controller"]; img != "example.com/cpc:v1" {
+		t.Fatalf("expected the current configuration to be kept, got: %s", img)
+	}
+}
@@ -1,28 +1,16 @@
 package configpolicy
 
 import (
-	"context"
 	"embed"
 	"errors"
 	"fmt"
-	"os"
 	"strconv"
-	"time"
+	"strings"
 
-	"github.com/openshift/library-go/pkg/controller/controllercmd"
 	corev1 "k8s.io/api/core/v1"
-	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"open-cluster-management.io/addon-framework/pkg/addonfactory"
-	"open-cluster-management.io/addon-framework/pkg/addonmanager"
-	"open-cluster-management.io/addon-framework/pkg/agent"
-	"open-cluster-management.io/addon-framework/pkg/utils"
 	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
-	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
-	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
-	addonlistersv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
-	clusterv1client "open-cluster-management.io/api/client/cluster/clientset/versioned"
-	clusterv1informers "open-cluster-management.io/api/client/cluster/informers/externalversions"
-	clusterlistersv1 "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 
@@ -35,16 +23,38 @@ const (
 	standaloneTemplatingAddonName    = "governance-standalone-hub-templating"
 )
 
+// The RBAC profiles of the controller on the managed cluster.
+const (
+	// RBACProfileFull grants every permission on the managed cluster.
+	RBACProfileFull = "full"
+	// RBACProfileNamespaced grants every permission in the listed namespaces only, with Roles and
+	// RoleBindings in each namespace.
+	RBACProfileNamespaced = "namespaced"
+	// RBACProfileCustom grants every permission on the resources of the listed API groups only.
+	RBACProfileCustom = "custom"
+)
+
 type configPolicyUserValues struct {
 	policyaddon.CommonValues `json:",inline"`
 
+	InformOnly                    bool            `json:"informOnly,omitempty"`
+	InformOnlySecrets             bool            `json:"informOnlySecrets,omitempty"`
 	ManagedKubeConfigSecret       string          `json:"managedKubeConfigSecret,omitempty"`
 	OperatorPolicy                *operatorPolicy `json:"operatorPolicy,omitempty"`
+	RBAC                          *rbac           `json:"rbac,omitempty"`
 	StandaloneHubTemplatingSecret string          `json:"standaloneHubTemplatingSecret,omitempty"`
 }
 
+type rbac struct {
+	Profile    string   `json:"profile,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	// APIGroups uses an empty string for the core API group.
+	APIGroups []string `json:"apiGroups,omitempty"`
+}
+
 type operatorPolicy struct {
-	Disabled         bool   `json:"disabled"`
+	// Disabled is a pointer so that the values only override it when it is set.
+	Disabled         *bool  `json:"disabled,omitempty"`
 	DefaultNamespace string `json:"defaultNamespace,omitempty"`
 }
 
@@ -78,9 +88,7 @@ func getSkeletonValues() configPolicyUserValues {
 				},
 			},
 		},
-		OperatorPolicy: &operatorPolicy{
-			Disabled: false,
-		},
+		OperatorPolicy: &operatorPolicy{},
 	}
 }
 
@@ -90,42 +98,152 @@ func (cpv *configPolicyUserValues) setOperatorPolicyDisabled(value string) error
 		return err
 	}
 
-	if cpv.OperatorPolicy != nil {
-		cpv.OperatorPolicy.Disabled = valBool
-	} else {
-		cpv.OperatorPolicy = &operatorPolicy{Disabled: valBool}
+	if cpv.OperatorPolicy == nil {
+		cpv.OperatorPolicy = &operatorPolicy{}
+	}
+
+	cpv.OperatorPolicy.Disabled = &valBool
+
+	return nil
+}
+
+func (cpv *configPolicyUserValues) setInformOnly(value string) error {
+	informOnly, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+
+	cpv.InformOnly = informOnly
+
+	return nil
+}
+
+func (cpv *configPolicyUserValues) setInformOnlySecrets(value string) error {
+	informOnlySecrets, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+
+	cpv.InformOnlySecrets = informOnlySecrets
+
+	return nil
+}
+
+func (cpv *configPolicyUserValues) setOperatorPolicyDefaultNamespace(value string) error {
+	if errs := validation.IsDNS1123Label(value); len(errs) > 0 {
+		return fmt.Errorf("invalid OperatorPolicy default namespace '%s': %v", value, errs)
+	}
+
+	if cpv.OperatorPolicy == nil {
+		cpv.OperatorPolicy = &operatorPolicy{}
+	}
+
+	cpv.OperatorPolicy.DefaultNamespace = value
+
+	return nil
+}
+
+func (cpv *configPolicyUserValues) setRBACProfile(value string) error {
+	switch value {
+	case RBACProfileFull, RBACProfileNamespaced, RBACProfileCustom:
+	default:
+		return fmt.Errorf("invalid RBAC profile '%s', expected %s, %s or %s", value,
+			RBACProfileFull, RBACProfileNamespaced, RBACProfileCustom)
+	}
+
+	if cpv.RBAC == nil {
+		cpv.RBAC = &rbac{}
+	}
+
+	cpv.RBAC.Profile = value
+
+	return nil
+}
+
+func (cpv *configPolicyUserValues) setRBACNamespaces(value string) error {
+	namespaces := []string{}
+
+	for _, namespace := range strings.Split(value, ",") {
+		namespace = strings.TrimSpace(namespace)
+		if namespace == "" {
+			continue
+		}
+
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return fmt.Errorf("invalid RBAC namespace '%s': %v", namespace, errs)
+		}
+
+		namespaces = append(namespaces, namespace)
+	}
+
+	if cpv.RBAC == nil {
+		cpv.RBAC = &rbac{}
+	}
+
+	cpv.RBAC.Namespaces = namespaces
+
+	return nil
+}
+
+// setRBACAPIGroups sets the API groups of the custom RBAC profile from a comma-separated list,
+// where "core" is the core API group.
+func (cpv *configPolicyUserValues) setRBACAPIGroups(value string) error {
+	apiGroups := []string{}
+
+	for _, apiGroup := range strings.Split(value, ",") {
+		apiGroup = strings.TrimSpace(apiGroup)
+
+		switch {
+		case apiGroup == "":
+			continue
+		case apiGroup == "core":
+			apiGroup = ""
+		case len(validation.IsDNS1123Subdomain(apiGroup)) > 0:
+			return fmt.Errorf("invalid RBAC API group '%s'", apiGroup)
+		}
+
+		apiGroups = append(apiGroups, apiGroup)
+	}
+
+	if cpv.RBAC == nil {
+		cpv.RBAC = &rbac{}
 	}
 
+	cpv.RBAC.APIGroups = apiGroups
+
 	return nil
 }
 
 func getValuesFromAnnotations(
-	clusterClient clusterlistersv1.ManagedClusterLister,
-	addonClient addonlistersv1alpha1.ManagedClusterAddOnLister,
+	clients *policyaddon.HubClients,
 ) func(*clusterv1.ManagedCluster, *addonapiv1beta1.ManagedClusterAddOn) (addonfactory.Values, error) {
 	return func(
 		cluster *clusterv1.ManagedCluster, addon *addonapiv1beta1.ManagedClusterAddOn,
 	) (addonfactory.Values, error) {
 		userValues := getSkeletonValues()
 
-		err := userValues.SetCommonValues(cluster, addon, clusterClient)
+		err := userValues.SetCommonValues(cluster, addon, clients)
 		if err != nil {
 			return nil, err
 		}
 
-		// Set the standalone hub templating secret if enabled
-		_, err = addonClient.ManagedClusterAddOns(addon.Namespace).Get(standaloneTemplatingAddonName)
-		if !k8serrors.IsNotFound(err) {
-			if err != nil {
-				return nil, err
-			}
+		// Set the standalone hub templating secret once it is issued, since the controller can't
+		// start until the secret can be mounted
+		state, err := getStandaloneTemplatingState(clients, addon.Namespace)
+		if err != nil {
+			return nil, err
+		}
 
+		if state == standaloneTemplatingReady {
 			userValues.StandaloneHubTemplatingSecret = standaloneTemplatingAddonName + "-hub-kubeconfig"
 		}
 
-		// Configure OperatorPolicy based on the cluster's OpenShift version
-		if cluster.Labels["openshiftVersion-major"] == "4" {
-			userValues.OperatorPolicy.DefaultNamespace = "openshift-operators"
+		// Configure OperatorPolicy based on whether OLM is installed on the cluster, keeping the
+		// chart defaults when it is unknown because a cluster other than OpenShift isn't labeled
+		if olm, known := policyaddon.GetClusterOLM(cluster); known {
+			disabled := !olm.Installed
+			userValues.OperatorPolicy.Disabled = &disabled
+			userValues.OperatorPolicy.DefaultNamespace = olm.GlobalOperatorsNamespace
 		}
 
 		if err := userValues.SetCommonValuesFromAnnotations(addon); err != nil {
@@ -156,7 +274,14 @@ func getValuesFromCustomizedVariableValues(config addonapiv1beta1.AddOnDeploymen
 
 	//nolint:unparam
 	variableToFuncMap := map[string]func(string) error{
-		"operatorPolicyDisabled": userValues.setOperatorPolicyDisabled,
+		"operatorPolicyDisabled":         userValues.setOperatorPolicyDisabled,
+		"operatorPolicyDefaultNamespace": userValues.setOperatorPolicyDefaultNamespace,
+		informOnlyVariable:               userValues.setInformOnly,
+		"informOnlySecrets":              userValues.setInformOnlySecrets,
+		rbacProfileVariable:              userValues.setRBACProfile,
+		"rbacNamespaces":                 userValues.setRBACNamespaces,
+		"rbacAPIGroups":                  userValues.setRBACAPIGroups,
+		// Validated by the post-render stage, which reports an invalid secret in the addon status
 		"managedKubeConfigSecret": func(value string) error {
 			userValues.ManagedKubeConfigSecret = value
 
@@ -178,84 +303,44 @@ func getValuesFromCustomizedVariableValues(config addonapiv1beta1.AddOnDeploymen
 		}
 	}
 
-	return addonfactory.JsonStructToValues(userValues)
-}
-
-func GetAgentAddon(ctx context.Context, controllerContext *controllercmd.ControllerContext) (agent.AgentAddon, error) {
-	registrationOption := policyaddon.NewRegistrationOption(ctx,
-		controllerContext,
-		addonName,
-		agentPermissionFiles,
-		FS,
-		false)
-
-	addonClient, err := addonv1alpha1client.NewForConfig(controllerContext.KubeConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve addon client: %w", err)
-	}
-
-	addonInformer := addoninformers.NewSharedInformerFactory(addonClient, 10*time.Minute).
-		Addon().V1alpha1().ManagedClusterAddOns()
-	go addonInformer.Informer().Run(ctx.Done())
-
-	clusterClient, err := clusterv1client.NewForConfig(controllerContext.KubeConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize a managed cluster client: %w", err)
+	if userValues.RBAC != nil {
+		switch {
+		case userValues.RBAC.Profile == RBACProfileNamespaced && len(userValues.RBAC.Namespaces) == 0:
+			log.Error(errors.New("no RBAC namespaces"), "the namespaced RBAC profile only grants the "+
+				"permissions on the policies without the rbacNamespaces customized variable")
+		case userValues.RBAC.Profile == RBACProfileCustom && len(userValues.RBAC.APIGroups) == 0:
+			log.Error(errors.New("no RBAC API groups"), "the custom RBAC profile only grants the "+
+				"permissions on the policies without the rbacAPIGroups customized variable")
+		}
 	}
 
-	clusterInformer := clusterv1informers.NewSharedInformerFactory(clusterClient, 10*time.Minute).
-		Cluster().V1().ManagedClusters()
-	go clusterInformer.Informer().Run(ctx.Done())
-
-	return addonfactory.NewAgentAddonFactory(addonName, FS, "manifests/managedclusterchart").
-		WithConfigGVRs(utils.AddOnDeploymentConfigGVR).
-		WithGetValuesFuncs(
-			getValuesFromAnnotations(clusterInformer.Lister(), addonInformer.Lister()),
-			addonfactory.GetValuesFromAddonAnnotation,
-			addonfactory.GetAddOnDeploymentConfigValues(
-				utils.NewAddOnDeploymentConfigGetter(addonClient),
-				addonfactory.ToAddOnNodePlacementValues,
-				addonfactory.ToAddOnResourceRequirementsValues,
-				getValuesFromCustomizedVariableValues,
-			),
-			policyaddon.MandateValues,
-			mandateImageFromEnv,
-		).
-		WithManagedClusterClient(clusterClient).
-		WithAgentRegistrationOption(registrationOption).
-		WithAgentInstallNamespace(
-			policyaddon.
-				CommonAgentInstallNamespaceFromDeploymentConfigFunc(utils.NewAddOnDeploymentConfigGetter(addonClient)),
-		).
-		WithScheme(policyaddon.Scheme).
-		WithAgentHostedModeEnabledOption().
-		BuildHelmAgentAddon()
-}
-
-func GetAndAddAgent(
-	ctx context.Context, mgr addonmanager.AddonManager, controllerContext *controllercmd.ControllerContext,
-) error {
-	return policyaddon.GetAndAddAgent(ctx, mgr, addonName, controllerContext, GetAgentAddon)
+	return addonfactory.JsonStructToValues(userValues)
 }
 
-// mandateImageFromEnv ensures that if the environment variable for the image is
-// set to a non-empty value, that value is used in the chart.
-func mandateImageFromEnv(
-	_ *clusterv1.ManagedCluster,
-	_ *addonapiv1beta1.ManagedClusterAddOn,
-) (addonfactory.Values, error) {
-	values := addonfactory.Values{}
-
-	img := os.Getenv("CONFIG_POLICY_CONTROLLER_IMAGE")
-	if img == "" {
-		return values, nil
-	}
-
-	values["global"] = map[string]any{
-		"imageOverrides": map[string]any{
-			"config_policy_controller": img,
-		},
-	}
-
-	return values, nil
+// Descriptor declares the config-policy-controller addon.
+var Descriptor = &policyaddon.AgentDescriptor{
+	Name:               addonName,
+	FS:                 FS,
+	HubPermissionFiles: agentPermissionFiles,
+	GetValuesFuncs: func(clients *policyaddon.HubClients) []addonfactory.GetValuesFunc {
+		return []addonfactory.GetValuesFunc{getValuesFromAnnotations(clients)}
+	},
+	CustomizedVariableValues: getValuesFromCustomizedVariableValues,
+	ImageEnvVar:              "CONFIG_POLICY_CONTROLLER_IMAGE",
+	ImageKey:                 "config_policy_controller",
+	TLSProfile:               true,
+	GoRuntimeEnv:             true,
+	ExtraArgs: []string{
+		"decryption-concurrency",
+		"evaluation-backoff",
+		"leader-elect-lease-duration",
+		"leader-elect-renew-deadline",
+		"leader-elect-retry-period",
+	},
+	PostRenderFuncs: func(clients *policyaddon.HubClients) []policyaddon.PostRenderFunc {
+		return []policyaddon.PostRenderFunc{
+			getManagedKubeConfigCheckFunc(), getInformOnlyConditionFunc(clients),
+			getRBACProfileConditionFunc(clients), getStandaloneTemplatingConditionFunc(clients),
+		}
+	},
 }
@@ -0,0 +1,113 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package configpolicy
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+)
+
+func TestHighAvailabilityRender(t *testing.T) {
+	tests := map[string]struct {
+		values               addonfactory.Values
+		expectedHA           bool
+		expectedAntiAffinity bool
+	}{
+		"single replica": {
+			values: addonfactory.Values{"replicas": 1},
+		},
+		"high availability": {
+			values:               addonfactory.Values{"replicas": 2},
+			expectedHA:           true,
+			expectedAntiAffinity: true,
+		},
+		"high availability with a pod anti-affinity": {
+			values: addonfactory.Values{
+				"replicas": 3,
+				"affinity": map[string]any{
+					"podAntiAffinity": map[string]any{
+						"requiredDuringSchedulingIgnoredDuringExecution": []any{map[string]any{
+							"topologyKey":   "topology.kubernetes.io/zone",
+							"labelSelector": map[string]any{"matchLabels": map[string]any{"app": addonName}},
+						}},
+					},
+				},
+			},
+			expectedHA: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			addon, getter := newTestAddon(nil, "")
+			objects := renderChart(t, addon, getter, test.values)
+
+			deployment := findObject[*appsv1.Deployment](objects, addonName)
+			if deployment == nil {
+				t.Fatal("expected the controller Deployment to be rendered")
+			}
+
+			pdb := findObject[*policyv1.PodDisruptionBudget](objects, addonName)
+			args := deployment.Spec.Template.Spec.Containers[0].Args
+			strategy := deployment.Spec.Strategy
+			affinity := deployment.Spec.Template.Spec.Affinity
+
+			if !test.expectedHA {
+				if strategy.Type != appsv1.RecreateDeploymentStrategyType {
+					t.Fatalf("expected the Recreate strategy, got: %s", strategy.Type)
+				}
+
+				if pdb != nil {
+					t.Fatal("expected no PodDisruptionBudget")
+				}
+
+				if !slices.Contains(args, "--leader-elect=false") {
+					t.Fatalf("expected leader election to be disabled, got: %v", args)
+				}
+
+				if affinity != nil && affinity.PodAntiAffinity != nil {
+					t.Fatalf("expected no pod anti-affinity, got: %v", affinity.PodAntiAffinity)
+				}
+
+				return
+			}
+
+			if strategy.Type != appsv1.RollingUpdateDeploymentStrategyType || strategy.RollingUpdate == nil ||
+				strategy.RollingUpdate.MaxUnavailable.IntValue() != 0 || strategy.RollingUpdate.MaxSurge.IntValue() != 1 {
+				t.Fatalf("expected a RollingUpdate strategy without unavailable replicas, got: %v", strategy)
+			}
+
+			if pdb == nil || pdb.Spec.MinAvailable == nil || pdb.Spec.MinAvailable.IntValue() != 1 {
+				t.Fatalf("expected a PodDisruptionBudget keeping a replica, got: %v", pdb)
+			}
+
+			if !maps.Equal(pdb.Spec.Selector.MatchLabels, deployment.Spec.Selector.MatchLabels) {
+				t.Fatalf("expected the PodDisruptionBudget to select the Deployment pods, got: %v",
+					pdb.Spec.Selector.MatchLabels)
+			}
+
+			if slices.Contains(args, "--leader-elect=false") {
+				t.Fatalf("expected leader election to be enabled, got: %v", args)
+			}
+
+			if affinity == nil || affinity.PodAntiAffinity == nil {
+				t.Fatal("expected a pod anti-affinity")
+			}
+
+			preferred := affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+			if test.expectedAntiAffinity {
+				if len(preferred) != 1 || preferred[0].PodAffinityTerm.TopologyKey != "kubernetes.io/hostname" {
+					t.Fatalf("expected the replicas to be spread across nodes, got: %v", affinity.PodAntiAffinity)
+				}
+			} else if len(preferred) != 0 ||
+				len(affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution) != 1 {
+				t.Fatalf("expected the pod anti-affinity value to be kept, got: %v", affinity.PodAntiAffinity)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package configpolicy
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	policyaddon "open-cluster-management.io/governance-policy-addon-controller/pkg/addon"
+)
+
+// ManagedKubeConfigInvalidReason is the reason of the Degraded condition of the ManagedClusterAddOn
+// when the managed kubeconfig secret of a hosted config-policy-controller is invalid.
+const ManagedKubeConfigInvalidReason = "ManagedKubeConfigSecretInvalid"
+
+// managedKubeConfigVolume is the volume of the managed kubeconfig secret, only rendered in hosted
+// mode.
+const managedKubeConfigVolume = "managed-kubeconfig-secret"
+
+// validateManagedKubeConfigSecret returns an error when the secret name isn't a valid name. Only the
+// syntax is validated, since the addon framework defaults the name and the secret is on the hosting
+// cluster.
+func validateManagedKubeConfigSecret(name string) error {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("invalid managed kubeconfig secret name '%s': %v", name, errs)
+	}
+
+	return nil
+}
+
+// managedKubeConfigSecret returns the secret name of the managed kubeconfig volume of the rendered
+// controller deployment, and false when it isn't deployed in hosted mode.
+func managedKubeConfigSecret(objects []runtime.Object) (string, bool) {
+	for _, obj := range objects {
+		deployment, ok := obj.(*appsv1.Deployment)
+		if !ok {
+			continue
+		}
+
+		for _, volume := range deployment.Spec.Template.Spec.Volumes {
+			if volume.Name != managedKubeConfigVolume {
+				continue
+			}
+
+			if volume.Secret == nil {
+				return "", true
+			}
+
+			return volume.Secret.SecretName, true
+		}
+	}
+
+	return "", false
+}
+
+// getManagedKubeConfigCheckFunc returns a post-render function that validates the name of the
+// managed kubeconfig secret of a hosted config-policy-controller. When it is invalid, it sets the
+// Degraded condition of the ManagedClusterAddOn and fails, so that the broken deployment isn't
+// applied on the hosting cluster. Whether the secret exists on the hosting cluster isn't checked.
+func getManagedKubeConfigCheckFunc() policyaddon.PostRenderFunc {
+	return func(
+		ctx context.Context,
+		_ *clusterv1.ManagedCluster,
+		addon *addonapiv1beta1.ManagedClusterAddOn,
+		objects []runtime.Object,
+	) ([]runtime.Object, error) {
+		var validationErr error
+
+		if secretName, hosted := managedKubeConfigSecret(objects); hosted {
+			validationErr = validateManagedKubeConfigSecret(secretName)
+		}
+
+		if validationErr != nil {
+			policyaddon.SetAddonCondition(ctx, metav1.Condition{
+				Type:    addonapiv1beta1.ManagedClusterAddOnConditionDegraded,
+				Status:  metav1.ConditionTrue,
+				Reason:  ManagedKubeConfigInvalidReason,
+				Message: validationErr.Error(),
+			})
+		} else if condition := meta.FindStatusCondition(
+			addon.Status.Conditions, addonapiv1beta1.ManagedClusterAddOnConditionDegraded,
+		); condition != nil && condition.Reason == ManagedKubeConfigInvalidReason {
+			// Only the Degraded condition set here is removed
+			policyaddon.RemoveAddonCondition(ctx, addonapiv1beta1.ManagedClusterAddOnConditionDegraded)
+		}
+
+		if validationErr != nil {
+			return nil, fmt.Errorf("not deploying the hosted %s: %w", addonName, validationErr)
+		}
+
+		return objects, nil
+	}
+}
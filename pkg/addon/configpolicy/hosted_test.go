@@ -0,0 +1,138 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package configpolicy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	policyaddon "open-cluster-management.io/governance-policy-addon-controller/pkg/addon"
+)
+
+func TestValidateManagedKubeConfigSecret(t *testing.T) {
+	tests := map[string]struct {
+		name        string
+		expectedErr bool
+	}{
+		"valid name":           {name: "external-managed-kubeconfig"},
+		"dotted name":          {name: "managed.kubeconfig"},
+		"empty name":           {expectedErr: true},
+		"uppercase name":       {name: "Managed-Kubeconfig", expectedErr: true},
+		"invalid characters":   {name: "managed_kubeconfig", expectedErr: true},
+		"leading dash in name": {name: "-managed-kubeconfig", expectedErr: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := validateManagedKubeConfigSecret(test.name)
+			if (err != nil) != test.expectedErr {
+				t.Fatalf("expected an error: %v, got: %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestManagedKubeConfigCheck(t *testing.T) {
+	tests := map[string]struct {
+		secret           string
+		values           addonfactory.Values
+		hostingCluster   string
+		degradedReason   string
+		expectedErr      bool
+		expectedDegraded bool
+		expectedRemoved  bool
+	}{
+		"default mode": {},
+		"valid secret": {
+			secret:         "external-managed-kubeconfig",
+			hostingCluster: "hosting-cluster",
+		},
+		// The addon framework defaults the secret name after the addon
+		"default secret": {
+			hostingCluster: "hosting-cluster",
+		},
+		"empty secret": {
+			values:           addonfactory.Values{"managedKubeConfigSecret": ""},
+			hostingCluster:   "hosting-cluster",
+			expectedErr:      true,
+			expectedDegraded: true,
+		},
+		"invalid secret": {
+			secret:           "Invalid_Secret",
+			hostingCluster:   "hosting-cluster",
+			expectedErr:      true,
+			expectedDegraded: true,
+		},
+		"fixed secret": {
+			secret:          "external-managed-kubeconfig",
+			hostingCluster:  "hosting-cluster",
+			degradedReason:  ManagedKubeConfigInvalidReason,
+			expectedRemoved: true,
+		},
+		// The Degraded condition set by another component is kept
+		"other degraded reason": {
+			secret:         "external-managed-kubeconfig",
+			hostingCluster: "hosting-cluster",
+			degradedReason: "OtherReason",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			variables := map[string]string{}
+			if test.secret != "" {
+				variables["managedKubeConfigSecret"] = test.secret
+			}
+
+			addon, getter := newTestAddon(variables, test.hostingCluster)
+			objects := renderChart(t, addon, getter, test.values)
+
+			if test.degradedReason != "" {
+				addon.Status.Conditions = []metav1.Condition{{
+					Type:   addonapiv1beta1.ManagedClusterAddOnConditionDegraded,
+					Status: metav1.ConditionTrue,
+					Reason: test.degradedReason,
+				}}
+			}
+
+			ctx, conditions := policyaddon.WithAddonConditions(context.TODO())
+
+			rendered, err := getManagedKubeConfigCheckFunc()(ctx, &clusterv1.ManagedCluster{}, addon, objects)
+			if (err != nil) != test.expectedErr {
+				t.Fatalf("expected an error: %v, got: %v", test.expectedErr, err)
+			}
+
+			if !test.expectedErr && len(rendered) != len(objects) {
+				t.Fatalf("expected the rendered objects to be returned, got: %d objects", len(rendered))
+			}
+
+			applied := conditions.Apply(addon.Status.Conditions)
+			degraded := meta.FindStatusCondition(applied, addonapiv1beta1.ManagedClusterAddOnConditionDegraded)
+
+			switch {
+			case test.expectedDegraded:
+				if degraded == nil || degraded.Status != metav1.ConditionTrue ||
+					degraded.Reason != ManagedKubeConfigInvalidReason {
+					t.Fatalf("expected the Degraded condition with the reason %s, got: %v",
+						ManagedKubeConfigInvalidReason, degraded)
+				}
+			case test.expectedRemoved:
+				if degraded != nil {
+					t.Fatalf("expected the Degraded condition to be removed, got: %v", degraded)
+				}
+			case test.degradedReason != "":
+				if degraded == nil || degraded.Reason != test.degradedReason {
+					t.Fatalf("expected the Degraded condition to be kept, got: %v", degraded)
+				}
+			case degraded != nil:
+				t.Fatalf("expected no Degraded condition, got: %v", degraded)
+			}
+		})
+	}
+}
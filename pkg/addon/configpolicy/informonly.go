@@ -0,0 +1,69 @@
+package configpolicy
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	policyaddon "open-cluster-management.io/governance-policy-addon-controller/pkg/addon"
+)
+
+// InformOnlyConditionType is the ManagedClusterAddOn condition set when the config-policy-controller
+// is deployed in inform-only mode.
+const InformOnlyConditionType = "InformOnly"
+
+// NotSupportedInHostedModeReason is the reason of the False InformOnly and RBACProfile conditions
+// when the customized variables restrict the permissions of a hosted config-policy-controller,
+// which has the permissions of its managed kubeconfig rather than the roles of the chart.
+const NotSupportedInHostedModeReason = "NotSupportedInHostedMode"
+
+const informOnlyVariable = "informOnly"
+
+// getInformOnlyConditionFunc returns a post-render function that reports whether the rendered
+// config-policy-controller is inform-only in the InformOnly condition of the ManagedClusterAddOn.
+// It doesn't change the rendered objects.
+func getInformOnlyConditionFunc(clients *policyaddon.HubClients) policyaddon.PostRenderFunc {
+	return func(
+		ctx context.Context,
+		_ *clusterv1.ManagedCluster,
+		addon *addonapiv1beta1.ManagedClusterAddOn,
+		objects []runtime.Object,
+	) ([]runtime.Object, error) {
+		variables, err := policyaddon.GetCustomizedVariables(clients.ADCGetter, addon)
+		if err != nil {
+			return nil, err
+		}
+
+		// An invalid value is logged when setting the chart values
+		userValues := configPolicyUserValues{}
+		_ = userValues.setInformOnly(variables[informOnlyVariable])
+
+		_, hosted := managedKubeConfigSecret(objects)
+
+		switch {
+		case !userValues.InformOnly:
+			policyaddon.RemoveAddonCondition(ctx, InformOnlyConditionType)
+		case hosted:
+			policyaddon.SetAddonCondition(ctx, metav1.Condition{
+				Type:   InformOnlyConditionType,
+				Status: metav1.ConditionFalse,
+				Reason: NotSupportedInHostedModeReason,
+				Message: "The inform-only mode doesn't apply in hosted mode, where the config-policy-controller " +
+					"has the permissions of the managed kubeconfig",
+			})
+		default:
+			policyaddon.SetAddonCondition(ctx, metav1.Condition{
+				Type:   InformOnlyConditionType,
+				Status: metav1.ConditionTrue,
+				Reason: "InformOnlyMode",
+				Message: "The config-policy-controller has read-only permissions on the cluster, so policies " +
+					"can't be enforced",
+			})
+		}
+
+		return objects, nil
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package configpolicy
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	policyaddon "open-cluster-management.io/governance-policy-addon-controller/pkg/addon"
+)
+
+func TestInformOnlyRBAC(t *testing.T) {
+	tests := map[string]struct {
+		variables          map[string]string
+		expectedWriteVerbs bool
+		expectedReadAll    bool
+	}{
+		"full profile": {
+			expectedWriteVerbs: true,
+		},
+		"inform-only": {
+			variables: map[string]string{"informOnly": "true"},
+		},
+		"inform-only with the secrets": {
+			variables:       map[string]string{"informOnly": "true", "informOnlySecrets": "true"},
+			expectedReadAll: true,
+		},
+		"inform-only with custom resources": {
+			variables: map[string]string{"informOnly": "true", "rbacAPIGroups": "core,config.openshift.io"},
+		},
+		"inform-only with the custom profile": {
+			variables: map[string]string{"informOnly": "true", "rbacProfile": "custom", "rbacAPIGroups": "apps"},
+		},
+		"inform-only with the namespaced profile": {
+			variables: map[string]string{"informOnly": "true", "rbacProfile": "namespaced", "rbacNamespaces": "app1"},
+		},
+		"custom profile": {
+			variables:          map[string]string{"rbacProfile": "custom", "rbacAPIGroups": "apps"},
+			expectedWriteVerbs: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			addon, getter := newTestAddon(test.variables, "")
+			objects := renderChart(t, addon, getter, nil)
+			rules := controllerRules(t, objects)
+
+			if verbs := writeVerbs(rules); (len(verbs) != 0) != test.expectedWriteVerbs {
+				t.Fatalf("expected write verbs: %v, got: %v", test.expectedWriteVerbs, verbs)
+			}
+
+			readAll := slices.ContainsFunc(rules, func(rule rbacv1.PolicyRule) bool {
+				return slices.Equal(rule.APIGroups, []string{"*"}) && slices.Equal(rule.Resources, []string{"*"})
+			})
+			if readAll != test.expectedReadAll && !test.expectedWriteVerbs {
+				t.Fatalf("expected the read access to every resource: %v, got: %v", test.expectedReadAll, rules)
+			}
+
+			// The controller can't enforce policies without an inform-only flag, since the RBAC denies
+			// any change outside of its own policies
+			if test.variables["informOnly"] == "true" {
+				for _, verb := range []string{"create", "update", "patch", "delete"} {
+					if ruleAllows(rules, "", "configmaps", verb) || ruleAllows(rules, "apps", "deployments", verb) {
+						t.Fatalf("expected the inform-only controller not to %s the resources, got: %v", verb, rules)
+					}
+				}
+			}
+
+			if test.variables["informOnly"] == "true" && test.variables["rbacProfile"] == "" {
+				readSecrets := test.variables["informOnlySecrets"] == "true"
+				if ruleAllows(rules, "", "secrets", "get") != readSecrets {
+					t.Fatalf("expected the secrets to be readable: %v, got: %v", readSecrets, rules)
+				}
+
+				if !ruleAllows(rules, "", "configmaps", "list") || !ruleAllows(rules, "apps", "deployments", "watch") {
+					t.Fatalf("expected the core and apps resources to be readable, got: %v", rules)
+				}
+
+				crdGroup := test.variables["rbacAPIGroups"] != "" || readSecrets
+				if ruleAllows(rules, "config.openshift.io", "clusterversions", "get") != crdGroup {
+					t.Fatalf("expected the custom resources to be readable: %v, got: %v", crdGroup, rules)
+				}
+			}
+
+			// The namespaced Roles are read-only too
+			for _, role := range findObjects[*rbacv1.Role](objects, controllerRoleName) {
+				if verbs := writeVerbs(role.Rules); len(verbs) != 0 && test.variables["informOnly"] == "true" {
+					t.Fatalf("expected the Role in %s to be read-only, got: %v", role.Namespace, verbs)
+				}
+			}
+
+			for _, obj := range objects {
+				if deployment, ok := obj.(*appsv1.Deployment); ok {
+					for _, arg := range deployment.Spec.Template.Spec.Containers[0].Args {
+						if strings.HasPrefix(arg, "--inform-only") {
+							t.Fatalf("expected no inform-only flag, which the controller doesn't accept, got: %s", arg)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestInformOnlyCondition(t *testing.T) {
+	tests := map[string]struct {
+		variables      map[string]string
+		hostingCluster string
+		expectedStatus metav1.ConditionStatus
+		expectedReason string
+	}{
+		"not inform-only": {},
+		"inform-only": {
+			variables:      map[string]string{"informOnly": "true"},
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: "InformOnlyMode",
+		},
+		"invalid value": {
+			variables: map[string]string{"informOnly": "maybe"},
+		},
+		"hosted mode": {
+			variables:      map[string]string{"informOnly": "true", "managedKubeConfigSecret": "external-kubeconfig"},
+			hostingCluster: "hosting-cluster",
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: NotSupportedInHostedModeReason,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			addon, getter := newTestAddon(test.variables, test.hostingCluster)
+			objects := renderChart(t, addon, getter, nil)
+
+			ctx, conditions := policyaddon.WithAddonConditions(context.TODO())
+
+			_, err := getInformOnlyConditionFunc(&policyaddon.HubClients{ADCGetter: getter})(
+				ctx, &clusterv1.ManagedCluster{}, addon, objects,
+			)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			condition := meta.FindStatusCondition(conditions.Apply(nil), InformOnlyConditionType)
+
+			if test.expectedStatus == "" {
+				if condition != nil {
+					t.Fatalf("expected no InformOnly condition, got: %v", condition)
+				}
+
+				return
+			}
+
+			if condition == nil || condition.Status != test.expectedStatus || condition.Reason != test.expectedReason {
+				t.Fatalf("expected the InformOnly condition %s with the reason %s, got: %v",
+					test.expectedStatus, test.expectedReason, condition)
+			}
+		})
+	}
+}
+
+// ruleAllows returns whether the rules allow the verb on the resource of the API group.
+func ruleAllows(rules []rbacv1.PolicyRule, apiGroup, resource, verb string) bool {
+	return slices.ContainsFunc(rules, func(rule rbacv1.PolicyRule) bool {
+		return (slices.Contains(rule.APIGroups, "*") || slices.Contains(rule.APIGroups, apiGroup)) &&
+			(slices.Contains(rule.Resources, "*") || slices.Contains(rule.Resources, resource)) &&
+			(slices.Contains(rule.Verbs, "*") || slices.Contains(rule.Verbs, verb)) &&
+			len(rule.ResourceNames) == 0
+	})
+}
@@ -0,0 +1,60 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package configpolicy
+
+import (
+	"testing"
+
+	prometheusv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+func TestServiceMonitorNamespace(t *testing.T) {
+	tests := map[string]struct {
+		variables       map[string]string
+		expectedAgentNS bool
+	}{
+		"without TLS": {
+			variables: map[string]string{},
+		},
+		"with a metrics certificate secret": {
+			variables:       map[string]string{"metricsCertSecret": "metrics-cert"},
+			expectedAgentNS: true,
+		},
+		"with a cert-manager issuer": {
+			variables:       map[string]string{"metricsCertIssuer": "ClusterIssuer/ca-issuer"},
+			expectedAgentNS: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.variables["prometheusEnabled"] = "true"
+			test.variables["prometheusRuleEnabled"] = "true"
+			test.variables["serviceMonitorNamespace"] = "monitoring"
+
+			addon, getter := newTestAddon(test.variables, "")
+			objects := renderChart(t, addon, getter, nil)
+
+			deployment := findObject[*appsv1.Deployment](objects, addonName)
+			if deployment == nil {
+				t.Fatal("expected the controller Deployment to be rendered")
+			}
+
+			expected := "monitoring"
+			if test.expectedAgentNS {
+				expected = deployment.Namespace
+			}
+
+			serviceMonitor := findObject[*prometheusv1.ServiceMonitor](objects, "ocm-"+addonName+"-metrics")
+			if serviceMonitor == nil || serviceMonitor.Namespace != expected {
+				t.Fatalf("expected the ServiceMonitor in the %s namespace, got: %v", expected, serviceMonitor)
+			}
+
+			prometheusRule := findObject[*prometheusv1.PrometheusRule](objects, "ocm-"+addonName+"-alerts")
+			if prometheusRule == nil || prometheusRule.Namespace != expected {
+				t.Fatalf("expected the PrometheusRule in the %s namespace, got: %v", expected, prometheusRule)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package configpolicy
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	policyaddon "open-cluster-management.io/governance-policy-addon-controller/pkg/addon"
+)
+
+// RBACProfileConditionType is the ManagedClusterAddOn condition set when the RBAC profile of the
+// config-policy-controller doesn't apply.
+const RBACProfileConditionType = "RBACProfile"
+
+const rbacProfileVariable = "rbacProfile"
+
+// getRBACProfileConditionFunc returns a post-render function that reports in the RBACProfile
+// condition of the ManagedClusterAddOn when a restricted RBAC profile is set for a hosted
+// config-policy-controller, where it doesn't apply. It doesn't change the rendered objects.
+func getRBACProfileConditionFunc(clients *policyaddon.HubClients) policyaddon.PostRenderFunc {
+	return func(
+		ctx context.Context,
+		_ *clusterv1.ManagedCluster,
+		addon *addonapiv1beta1.ManagedClusterAddOn,
+		objects []runtime.Object,
+	) ([]runtime.Object, error) {
+		variables, err := policyaddon.GetCustomizedVariables(clients.ADCGetter, addon)
+		if err != nil {
+			return nil, err
+		}
+
+		profile := variables[rbacProfileVariable]
+		_, hosted := managedKubeConfigSecret(objects)
+
+		if !hosted || profile == "" || profile == RBACProfileFull {
+			policyaddon.RemoveAddonCondition(ctx, RBACProfileConditionType)
+
+			return objects, nil
+		}
+
+		policyaddon.SetAddonCondition(ctx, metav1.Condition{
+			Type:   RBACProfileConditionType,
+			Status: metav1.ConditionFalse,
+			Reason: NotSupportedInHostedModeReason,
+			Message: "The " + profile + " RBAC profile doesn't apply in hosted mode, where the " +
+				"config-policy-controller has the permissions of the managed kubeconfig",
+		})
+
+		return objects, nil
+	}
+}
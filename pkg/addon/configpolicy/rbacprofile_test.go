@@ -0,0 +1,153 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package configpolicy
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	policyaddon "open-cluster-management.io/governance-policy-addon-controller/pkg/addon"
+)
+
+func TestRBACProfileRender(t *testing.T) {
+	hasRule := func(rules []rbacv1.PolicyRule, apiGroups, resources, verbs []string) bool {
+		return slices.ContainsFunc(rules, func(rule rbacv1.PolicyRule) bool {
+			return slices.Equal(rule.APIGroups, apiGroups) && slices.Equal(rule.Resources, resources) &&
+				slices.Equal(rule.Verbs, verbs)
+		})
+	}
+
+	t.Run("full", func(t *testing.T) {
+		addon, getter := newTestAddon(nil, "")
+		objects := renderChart(t, addon, getter, nil)
+
+		if !hasRule(controllerRules(t, objects), []string{"*"}, []string{"*"}, []string{"*"}) {
+			t.Fatalf("expected every permission, got: %v", controllerRules(t, objects))
+		}
+
+		if roles := findObjects[*rbacv1.Role](objects, controllerRoleName); len(roles) != 0 {
+			t.Fatalf("expected no namespaced Roles, got: %d", len(roles))
+		}
+	})
+
+	t.Run("namespaced", func(t *testing.T) {
+		addon, getter := newTestAddon(map[string]string{
+			"rbacProfile": "namespaced", "rbacNamespaces": "app1,app2",
+		}, "")
+		objects := renderChart(t, addon, getter, nil)
+
+		if verbs := writeVerbs(controllerRules(t, objects)); len(verbs) != 0 {
+			t.Fatalf("expected the ClusterRole to only write the policies, got: %v", verbs)
+		}
+
+		roles := findObjects[*rbacv1.Role](objects, controllerRoleName)
+		bindings := findObjects[*rbacv1.RoleBinding](objects, controllerRoleName)
+
+		namespaces := []string{}
+		for _, role := range roles {
+			namespaces = append(namespaces, role.Namespace)
+
+			if !hasRule(role.Rules, []string{"*"}, []string{"*"}, []string{"*"}) {
+				t.Fatalf("expected every permission in %s, got: %v", role.Namespace, role.Rules)
+			}
+		}
+
+		slices.Sort(namespaces)
+
+		if !slices.Equal(namespaces, []string{"app1", "app2"}) || len(bindings) != 2 {
+			t.Fatalf("expected a Role and RoleBinding in app1 and app2, got the Roles in %v and %d RoleBindings",
+				namespaces, len(bindings))
+		}
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		addon, getter := newTestAddon(map[string]string{
+			"rbacProfile": "custom", "rbacAPIGroups": "core,apps",
+		}, "")
+		rules := controllerRules(t, renderChart(t, addon, getter, nil))
+
+		if !hasRule(rules, []string{"", "apps"}, []string{"*"}, []string{"*"}) {
+			t.Fatalf("expected every permission on the core and apps API groups, got: %v", rules)
+		}
+
+		if hasRule(rules, []string{"*"}, []string{"*"}, []string{"*"}) {
+			t.Fatalf("expected no permission on every API group, got: %v", rules)
+		}
+	})
+
+	t.Run("hosted", func(t *testing.T) {
+		addon, getter := newTestAddon(map[string]string{
+			"rbacProfile": "namespaced", "rbacNamespaces": "app1", "managedKubeConfigSecret": "external-kubeconfig",
+		}, "hosting-cluster")
+		objects := renderChart(t, addon, getter, nil)
+
+		if clusterRole := findObject[*rbacv1.ClusterRole](objects, controllerRoleName); clusterRole != nil {
+			t.Fatal("expected no ClusterRole in hosted mode")
+		}
+
+		// Only the Role on the hosting cluster is rendered, in the install namespace
+		if roles := findObjects[*rbacv1.Role](objects, controllerRoleName); len(roles) != 1 {
+			t.Fatalf("expected only the hosting Role, got: %d Roles", len(roles))
+		}
+	})
+}
+
+func TestRBACProfileCondition(t *testing.T) {
+	tests := map[string]struct {
+		variables      map[string]string
+		hostingCluster string
+		expected       bool
+	}{
+		"restricted profile": {
+			variables: map[string]string{"rbacProfile": "custom", "rbacAPIGroups": "apps"},
+		},
+		"full profile in hosted mode": {
+			variables:      map[string]string{"rbacProfile": "full", "managedKubeConfigSecret": "external-kubeconfig"},
+			hostingCluster: "hosting-cluster",
+		},
+		"restricted profile in hosted mode": {
+			variables: map[string]string{
+				"rbacProfile": "namespaced", "rbacNamespaces": "app1", "managedKubeConfigSecret": "external-kubeconfig",
+			},
+			hostingCluster: "hosting-cluster",
+			expected:       true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			addon, getter := newTestAddon(test.variables, test.hostingCluster)
+			objects := renderChart(t, addon, getter, nil)
+
+			ctx, conditions := policyaddon.WithAddonConditions(context.TODO())
+
+			_, err := getRBACProfileConditionFunc(&policyaddon.HubClients{ADCGetter: getter})(
+				ctx, &clusterv1.ManagedCluster{}, addon, objects,
+			)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			condition := meta.FindStatusCondition(conditions.Apply(nil), RBACProfileConditionType)
+
+			if !test.expected {
+				if condition != nil {
+					t.Fatalf("expected no RBACProfile condition, got: %v", condition)
+				}
+
+				return
+			}
+
+			if condition == nil || condition.Status != metav1.ConditionFalse ||
+				condition.Reason != NotSupportedInHostedModeReason {
+				t.Fatalf("expected the RBACProfile condition to be False as not supported, got: %v", condition)
+			}
+		})
+	}
+}
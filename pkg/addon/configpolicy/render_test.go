@@ -0,0 +1,191 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package configpolicy
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	policyaddon "open-cluster-management.io/governance-policy-addon-controller/pkg/addon"
+)
+
+const controllerRoleName = "open-cluster-management:" + addonName
+
+type fakeADCGetter struct {
+	config *addonapiv1beta1.AddOnDeploymentConfig
+}
+
+func (f *fakeADCGetter) Get(_ context.Context, _, _ string) (*addonapiv1beta1.AddOnDeploymentConfig, error) {
+	return f.config, nil
+}
+
+// newTestAddon returns a config-policy-controller ManagedClusterAddOn referencing an
+// AddOnDeploymentConfig with the customized variables, hosted on the hosting cluster when it is
+// set.
+func newTestAddon(
+	variables map[string]string, hostingCluster string,
+) (*addonapiv1beta1.ManagedClusterAddOn, utils.AddOnDeploymentConfigGetter) {
+	addon := &addonapiv1beta1.ManagedClusterAddOn{
+		ObjectMeta: metav1.ObjectMeta{Name: addonName, Namespace: "cluster1"},
+		Status: addonapiv1beta1.ManagedClusterAddOnStatus{
+			ConfigReferences: []addonapiv1beta1.ConfigReference{{
+				ConfigGroupResource: addonapiv1beta1.ConfigGroupResource{
+					Group:    utils.AddOnDeploymentConfigGVR.Group,
+					Resource: utils.AddOnDeploymentConfigGVR.Resource,
+				},
+				DesiredConfig: &addonapiv1beta1.ConfigSpecHash{
+					ConfigReferent: addonapiv1beta1.ConfigReferent{Namespace: "cluster1", Name: "config"},
+					SpecHash:       "hash",
+				},
+			}},
+		},
+	}
+
+	if hostingCluster != "" {
+		addon.Annotations = map[string]string{addonapiv1beta1.HostingClusterNameAnnotationKey: hostingCluster}
+	}
+
+	config := &addonapiv1beta1.AddOnDeploymentConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "cluster1"},
+	}
+
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	for _, name := range names {
+		config.Spec.CustomizedVariables = append(config.Spec.CustomizedVariables,
+			addonapiv1beta1.CustomizedVariable{Name: name, Value: variables[name]})
+	}
+
+	return addon, &fakeADCGetter{config: config}
+}
+
+// renderChart renders the config-policy-controller chart for the addon with the values of its
+// customized variables, and then the values, which take precedence.
+func renderChart(
+	t *testing.T,
+	addon *addonapiv1beta1.ManagedClusterAddOn,
+	getter utils.AddOnDeploymentConfigGetter,
+	values addonfactory.Values,
+) []runtime.Object {
+	t.Helper()
+
+	getVariableValues := func(
+		_ *clusterv1.ManagedCluster, addon *addonapiv1beta1.ManagedClusterAddOn,
+	) (addonfactory.Values, error) {
+		config, err := utils.GetDesiredAddOnDeploymentConfig(addon, getter)
+		if err != nil {
+			return nil, err
+		}
+
+		return getValuesFromCustomizedVariableValues(*config)
+	}
+
+	getValues := func(
+		_ *clusterv1.ManagedCluster, _ *addonapiv1beta1.ManagedClusterAddOn,
+	) (addonfactory.Values, error) {
+		return values, nil
+	}
+
+	agentAddon, err := addonfactory.NewAgentAddonFactory(addonName, FS, "manifests/managedclusterchart").
+		WithGetValuesFuncs(getVariableValues, getValues).
+		WithScheme(policyaddon.Scheme).
+		WithAgentHostedModeEnabledOption().
+		BuildHelmAgentAddon()
+	if err != nil {
+		t.Fatalf("expected the addon to build, got: %v", err)
+	}
+
+	cluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+	cluster.Status.Version.Kubernetes = "v1.30.0"
+
+	objects, err := agentAddon.Manifests(context.TODO(), cluster, addon)
+	if err != nil {
+		t.Fatalf("expected the chart to render, got: %v", err)
+	}
+
+	return objects
+}
+
+// namedObject is a rendered object of a specific type.
+type namedObject interface {
+	runtime.Object
+	GetName() string
+}
+
+// findObjects returns the rendered objects of the type with the name, in any namespace.
+func findObjects[T namedObject](objects []runtime.Object, name string) []T {
+	found := []T{}
+
+	for _, obj := range objects {
+		if typed, ok := obj.(T); ok && typed.GetName() == name {
+			found = append(found, typed)
+		}
+	}
+
+	return found
+}
+
+// findObject returns the first rendered object of the type with the name, or nil.
+func findObject[T namedObject](objects []runtime.Object, name string) T {
+	var zero T
+
+	if found := findObjects[T](objects, name); len(found) != 0 {
+		return found[0]
+	}
+
+	return zero
+}
+
+// controllerRules returns the rules of the config-policy-controller ClusterRole, or of its Role in
+// hosted mode.
+func controllerRules(t *testing.T, objects []runtime.Object) []rbacv1.PolicyRule {
+	t.Helper()
+
+	if clusterRole := findObject[*rbacv1.ClusterRole](objects, controllerRoleName); clusterRole != nil {
+		return clusterRole.Rules
+	}
+
+	if role := findObject[*rbacv1.Role](objects, controllerRoleName); role != nil {
+		return role.Rules
+	}
+
+	t.Fatal("expected the controller role to be rendered")
+
+	return nil
+}
+
+// writeVerbs returns the verbs of the rules which change the resources of the API groups, other
+// than the policies, their status and finalizers, events, and the controller deployment.
+func writeVerbs(rules []rbacv1.PolicyRule) []string {
+	verbs := []string{}
+
+	for _, rule := range rules {
+		if slices.Contains(rule.APIGroups, "policy.open-cluster-management.io") ||
+			slices.Contains(rule.Resources, "events") || len(rule.ResourceNames) != 0 ||
+			len(rule.NonResourceURLs) != 0 {
+			continue
+		}
+
+		for _, verb := range rule.Verbs {
+			if !slices.Contains([]string{"get", "list", "watch"}, verb) {
+				verbs = append(verbs, verb)
+			}
+		}
+	}
+
+	return verbs
+}
@@ -0,0 +1,91 @@
+package configpolicy
+
+import (
+	"context"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	policyaddon "open-cluster-management.io/governance-policy-addon-controller/pkg/addon"
+)
+
+// StandaloneHubTemplatingConditionType is the ManagedClusterAddOn condition reporting whether the
+// config-policy-controller uses the governance-standalone-hub-templating addon, or is waiting for
+// it to be ready.
+const StandaloneHubTemplatingConditionType = "StandaloneHubTemplating"
+
+// The states of the governance-standalone-hub-templating addon on a managed cluster.
+type standaloneTemplatingState int
+
+const (
+	standaloneTemplatingAbsent standaloneTemplatingState = iota
+	standaloneTemplatingWaiting
+	standaloneTemplatingReady
+)
+
+// getStandaloneTemplatingState returns whether the governance-standalone-hub-templating addon is
+// installed in the cluster namespace, and whether it is registered with its hub kubeconfig secret
+// issued, so that the config-policy-controller can mount the secret.
+func getStandaloneTemplatingState(
+	clients *policyaddon.HubClients, clusterNamespace string,
+) (standaloneTemplatingState, error) {
+	addon, err := clients.AddonLister.ManagedClusterAddOns(clusterNamespace).Get(standaloneTemplatingAddonName)
+	if k8serrors.IsNotFound(err) {
+		return standaloneTemplatingAbsent, nil
+	}
+
+	if err != nil {
+		return standaloneTemplatingAbsent, err
+	}
+
+	if meta.IsStatusConditionTrue(addon.Status.Conditions, addonapiv1alpha1.ManagedClusterAddOnRegistrationApplied) &&
+		meta.IsStatusConditionTrue(addon.Status.Conditions, policyaddon.ClusterCertificateRotatedCondition) {
+		return standaloneTemplatingReady, nil
+	}
+
+	return standaloneTemplatingWaiting, nil
+}
+
+// getStandaloneTemplatingConditionFunc returns a post-render function that reports the state of
+// the governance-standalone-hub-templating addon in the StandaloneHubTemplating condition of the
+// ManagedClusterAddOn. It doesn't change the rendered objects.
+func getStandaloneTemplatingConditionFunc(clients *policyaddon.HubClients) policyaddon.PostRenderFunc {
+	return func(
+		ctx context.Context,
+		_ *clusterv1.ManagedCluster,
+		addon *addonapiv1beta1.ManagedClusterAddOn,
+		objects []runtime.Object,
+	) ([]runtime.Object, error) {
+		state, err := getStandaloneTemplatingState(clients, addon.Namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		switch state {
+		case standaloneTemplatingReady:
+			policyaddon.SetAddonCondition(ctx, metav1.Condition{
+				Type:    StandaloneHubTemplatingConditionType,
+				Status:  metav1.ConditionTrue,
+				Reason:  "Enabled",
+				Message: "The config-policy-controller resolves hub templates with the " + standaloneTemplatingAddonName,
+			})
+		case standaloneTemplatingWaiting:
+			policyaddon.SetAddonCondition(ctx, metav1.Condition{
+				Type:   StandaloneHubTemplatingConditionType,
+				Status: metav1.ConditionFalse,
+				Reason: "WaitingForAddon",
+				Message: "Waiting for the " + standaloneTemplatingAddonName + " addon to be registered and " +
+					"its hub kubeconfig to be issued",
+			})
+		default:
+			policyaddon.RemoveAddonCondition(ctx, StandaloneHubTemplatingConditionType)
+		}
+
+		return objects, nil
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package configpolicy
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	addonlistersv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	policyaddon "open-cluster-management.io/governance-policy-addon-controller/pkg/addon"
+)
+
+// newStandaloneTemplatingClients returns the hub clients with an addon lister holding the
+// governance-standalone-hub-templating addon of cluster1 with the conditions, or no addon when the
+// conditions are nil.
+func newStandaloneTemplatingClients(t *testing.T, conditions []metav1.Condition) *policyaddon.HubClients {
+	t.Helper()
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+
+	if conditions != nil {
+		err := indexer.Add(&addonapiv1alpha1.ManagedClusterAddOn{
+			ObjectMeta: metav1.ObjectMeta{Name: standaloneTemplatingAddonName, Namespace: "cluster1"},
+			Status:     addonapiv1alpha1.ManagedClusterAddOnStatus{Conditions: conditions},
+		})
+		if err != nil {
+			t.Fatalf("expected the addon to be added, got: %v", err)
+		}
+	}
+
+	return &policyaddon.HubClients{AddonLister: addonlistersv1alpha1.NewManagedClusterAddOnLister(indexer)}
+}
+
+func TestStandaloneTemplatingCondition(t *testing.T) {
+	registered := metav1.Condition{
+		Type:   addonapiv1alpha1.ManagedClusterAddOnRegistrationApplied,
+		Status: metav1.ConditionTrue,
+		Reason: "Registered",
+	}
+	rotated := metav1.Condition{
+		Type: policyaddon.ClusterCertificateRotatedCondition, Status: metav1.ConditionTrue, Reason: "Rotated",
+	}
+	notRotated := metav1.Condition{
+		Type: policyaddon.ClusterCertificateRotatedCondition, Status: metav1.ConditionFalse, Reason: "Pending",
+	}
+
+	tests := map[string]struct {
+		conditions     []metav1.Condition
+		expectedState  standaloneTemplatingState
+		expectedStatus metav1.ConditionStatus
+		expectedReason string
+	}{
+		"no addon": {
+			expectedState: standaloneTemplatingAbsent,
+		},
+		"new addon": {
+			conditions:     []metav1.Condition{},
+			expectedState:  standaloneTemplatingWaiting,
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: "WaitingForAddon",
+		},
+		"registered without certificate": {
+			conditions:     []metav1.Condition{registered},
+			expectedState:  standaloneTemplatingWaiting,
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: "WaitingForAddon",
+		},
+		"registered with a pending certificate": {
+			conditions:     []metav1.Condition{registered, notRotated},
+			expectedState:  standaloneTemplatingWaiting,
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: "WaitingForAddon",
+		},
+		"certificate without registration": {
+			conditions:     []metav1.Condition{rotated},
+			expectedState:  standaloneTemplatingWaiting,
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: "WaitingForAddon",
+		},
+		"ready": {
+			conditions:     []metav1.Condition{registered, rotated},
+			expectedState:  standaloneTemplatingReady,
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: "Enabled",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients := newStandaloneTemplatingClients(t, test.conditions)
+
+			state, err := getStandaloneTemplatingState(clients, "cluster1")
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if state != test.expectedState {
+				t.Fatalf("expected the state %d, got: %d", test.expectedState, state)
+			}
+
+			addon := &addonapiv1beta1.ManagedClusterAddOn{
+				ObjectMeta: metav1.ObjectMeta{Name: addonName, Namespace: "cluster1"},
+				Status: addonapiv1beta1.ManagedClusterAddOnStatus{
+					Conditions: []metav1.Condition{{
+						Type: StandaloneHubTemplatingConditionType, Status: metav1.ConditionTrue, Reason: "Enabled",
+					}},
+				},
+			}
+
+			ctx, conditions := policyaddon.WithAddonConditions(context.TODO())
+
+			_, err = getStandaloneTemplatingConditionFunc(clients)(ctx, &clusterv1.ManagedCluster{}, addon, nil)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			condition := meta.FindStatusCondition(
+				conditions.Apply(addon.Status.Conditions), StandaloneHubTemplatingConditionType,
+			)
+
+			if test.expectedStatus == "" {
+				if condition != nil {
+					t.Fatalf("expected the StandaloneHubTemplating condition to be removed, got: %v", condition)
+				}
+
+				return
+			}
+
+			if condition == nil || condition.Status != test.expectedStatus || condition.Reason != test.expectedReason {
+				t.Fatalf("expected the StandaloneHubTemplating condition %s with the reason %s, got: %v",
+					test.expectedStatus, test.expectedReason, condition)
+			}
+		})
+	}
+}
@@ -0,0 +1,623 @@
+package addon
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	configv1client "github.com/openshift/client-go/config/clientset/versioned"
+	"github.com/openshift/library-go/pkg/controller/controllercmd"
+	"helm.sh/helm/v3/pkg/chartutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	addonlistersv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+	clusterv1client "open-cluster-management.io/api/client/cluster/clientset/versioned"
+	clusterv1informers "open-cluster-management.io/api/client/cluster/informers/externalversions"
+	clusterlistersv1 "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+// ChartDir is the directory of the managed cluster chart in an addon's filesystem.
+const ChartDir = "manifests/managedclusterchart"
+
+// AgentDescriptor declares a policy addon. Each descriptor is turned into an
+// agent addon with the same common wiring by AddAgents.
+type AgentDescriptor struct {
+	// Name is the name of the addon, which is also used as the Helm release name.
+	Name string
+	// FS contains the addon's chart in ChartDir along with its hub permission files.
+	// Charts compiled into the controller are embedded, and others are read from disk.
+	FS fs.FS
+	// HubPermissionFiles are templates in FS applied on the hub when the addon is registered.
+	HubPermissionFiles []string
+	// HubPermissionsForAddon binds the hub permissions to the group for the entire addon
+	// instead of the cluster-specific group.
+	HubPermissionsForAddon bool
+	// GetValuesFuncs returns the addon-specific values functions. These have the lowest
+	// priority and are overridden by the values annotation and the AddOnDeploymentConfig.
+	GetValuesFuncs func(clients *HubClients) []addonfactory.GetValuesFunc
+	// CustomizedVariableValues converts the customized variables of the AddOnDeploymentConfig
+	// to chart values.
+	CustomizedVariableValues addonfactory.AddOnDeploymentConfigToValuesFunc
+	// MandatedValuesFuncs returns the values functions of the values owned by the addon, which
+	// override the values annotation and the AddOnDeploymentConfig.
+	MandatedValuesFuncs func(clients *HubClients) []addonfactory.GetValuesFunc
+	// ImageEnvVar is the environment variable that, when set, mandates the image in the
+	// chart's global.imageOverrides at ImageKey. The images of the controller configuration
+	// take precedence over it. The registries of the AddOnDeploymentConfig
+	// are applied to the image at ImageKey, whether or not it comes from the environment.
+	ImageEnvVar string
+	ImageKey    string
+	// TLSProfile passes the hub TLS profile to the agent in the tlsMinVersion and tlsCipherSuites
+	// values, and reports the effective TLS settings in the TLSProfile condition of the
+	// ManagedClusterAddOn.
+	TLSProfile bool
+	// ExtraArgs is the allowlist of the flag names, without the leading dashes, accepted in the
+	// extraArgs customized variable. The flags are appended to the first container of the
+	// Deployment named after the addon, and must not be set by the chart.
+	ExtraArgs []string
+	// GoRuntimeEnv sets GOMEMLIMIT, and optionally GOMAXPROCS, in the agent container from its
+	// effective resource limits. It is only meant for agents built with Go.
+	GoRuntimeEnv bool
+	// NoAgent is set for the addons whose chart doesn't deploy an agent. They don't get the values
+	// annotation, the resource requirements and proxy settings of the AddOnDeploymentConfig, or the
+	// uninstallation value.
+	NoAgent bool
+	// PostRenderFuncs optionally returns addon-specific post-render functions, which run after
+	// the common ones.
+	PostRenderFuncs func(clients *HubClients) []PostRenderFunc
+	// Optional addons are skipped, with an error logged and a warning event, when they can't be
+	// set up, instead of stopping the controller.
+	Optional bool
+	// HubController optionally runs a hub controller of the addon until the context is canceled. It
+	// is started by AddAgents once the addon is added, and can trigger the addon with the manager.
+	HubController func(ctx context.Context, mgr addonmanager.AddonManager, clients *HubClients)
+	// WrapAgent optionally wraps the built agent addon to override more of its behavior.
+	WrapAgent func(agentAddon agent.AgentAddon, mgr addonmanager.AddonManager) agent.AgentAddon
+}
+
+// AgentOptions configures how the descriptors are built into agent addons.
+type AgentOptions struct {
+	// ChartOverlayDir is a directory whose files replace the matching files in the addons'
+	// charts at render time. Its layout is <addon name>/<path relative to ChartDir>.
+	ChartOverlayDir string
+	// HubAPIServer is the URL of the hub API server, whose host is added to the hosts that
+	// aren't proxied when a proxy is configured. When it is empty, it is discovered on startup.
+	HubAPIServer string
+	// ControllerConfigFile is the controller configuration file, which is reloaded when it changes.
+	ControllerConfigFile string
+}
+
+// HubClients contains the hub clients and listers shared by the policy addons.
+type HubClients struct {
+	KubeClient    kubernetes.Interface
+	AddonClient   addonv1alpha1client.Interface
+	ClusterClient clusterv1client.Interface
+	ConfigClient  configv1client.Interface
+	DynamicClient dynamic.Interface
+	AddonLister   addonlistersv1alpha1.ManagedClusterAddOnLister
+	ClusterLister clusterlistersv1.ManagedClusterLister
+	ADCGetter     utils.AddOnDeploymentConfigGetter
+	// KubeInformers, AddonInformers and ClusterInformers are the started informer factories of the
+	// hub. The informers requested later are only run once the factory is started again.
+	KubeInformers      informers.SharedInformerFactory
+	AddonInformers     addoninformers.SharedInformerFactory
+	ClusterInformers   clusterv1informers.SharedInformerFactory
+	ConfigMapLister    corev1listers.ConfigMapLister
+	ClusterAddonLister addonlistersv1alpha1.ClusterManagementAddOnLister
+	// TLSProfile is the hub-wide TLS profile of the agents, which is set up by AddAgents.
+	TLSProfile *HubTLSProfile
+	// HubAPIServer and HubCA are the URL and the PEM CA bundle of the hub API server that the
+	// managed clusters connect to, which are set up by AddAgents.
+	HubAPIServer string
+	HubCA        []byte
+
+	configMapInformer     cache.SharedIndexInformer
+	postRenderPatchesRefs *postRenderPatchesRefs
+}
+
+// NewHubClients creates the hub clients and starts the informers backing the listers.
+func NewHubClients(ctx context.Context, kubeConfig *rest.Config) (*HubClients, error) {
+	kubeClient, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kube client: %w", err)
+	}
+
+	addonClient, err := addonv1alpha1client.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve addon client: %w", err)
+	}
+
+	addonInformers := addoninformers.NewSharedInformerFactory(addonClient, 10*time.Minute)
+	addonInformer := addonInformers.Addon().V1alpha1().ManagedClusterAddOns()
+	clusterAddonInformer := addonInformers.Addon().V1alpha1().ClusterManagementAddOns()
+
+	// Request the informers before starting the factory
+	addonInformer.Informer()
+	clusterAddonInformer.Informer()
+	addonInformers.Start(ctx.Done())
+
+	kubeInformers := informers.NewSharedInformerFactory(kubeClient, 10*time.Minute)
+	kubeInformers.Start(ctx.Done())
+
+	// Only the labeled post-render patches ConfigMaps are cached, rather than all the ConfigMaps of the hub
+	configMapInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 10*time.Minute,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = PostRenderPatchesLabel + "=true"
+		}),
+	)
+	configMapInformer := configMapInformers.Core().V1().ConfigMaps()
+
+	configMapInformer.Informer()
+	configMapInformers.Start(ctx.Done())
+
+	clusterClient, err := clusterv1client.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize a managed cluster client: %w", err)
+	}
+
+	clusterInformers := clusterv1informers.NewSharedInformerFactory(clusterClient, 10*time.Minute)
+	clusterInformer := clusterInformers.Cluster().V1().ManagedClusters()
+
+	clusterInformer.Informer()
+	clusterInformers.Start(ctx.Done())
+
+	configClient, err := configv1client.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the OpenShift config client: %w", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the dynamic client: %w", err)
+	}
+
+	// The post-render patches are read from the caches when rendering
+	for informerType, synced := range configMapInformers.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return nil, fmt.Errorf("failed to sync the %v informer", informerType)
+		}
+	}
+
+	for informerType, synced := range addonInformers.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return nil, fmt.Errorf("failed to sync the %v informer", informerType)
+		}
+	}
+
+	return &HubClients{
+		KubeClient:    kubeClient,
+		AddonClient:   addonClient,
+		ClusterClient: clusterClient,
+		ConfigClient:  configClient,
+		DynamicClient: dynamicClient,
+		AddonLister:   addonInformer.Lister(),
+		ClusterLister: clusterInformer.Lister(),
+		ADCGetter:     utils.NewAddOnDeploymentConfigGetter(addonClient),
+
+		KubeInformers:      kubeInformers,
+		AddonInformers:     addonInformers,
+		ClusterInformers:   clusterInformers,
+		ConfigMapLister:    configMapInformer.Lister(),
+		ClusterAddonLister: clusterAddonInformer.Lister(),
+
+		configMapInformer:     configMapInformer.Informer(),
+		postRenderPatchesRefs: newPostRenderPatchesRefs(),
+	}, nil
+}
+
+// BuildAgentAddon turns the descriptor into an agent addon with the common values
+// pipeline, registration, install namespace and hosted mode configuration.
+func BuildAgentAddon(
+	ctx context.Context,
+	controllerContext *controllercmd.ControllerContext,
+	clients *HubClients,
+	opts AgentOptions,
+	desc *AgentDescriptor,
+) (agent.AgentAddon, error) {
+	registrationOption := NewRegistrationOption(ctx,
+		controllerContext,
+		desc.Name,
+		desc.HubPermissionFiles,
+		desc.FS,
+		desc.HubPermissionsForAddon)
+
+	getValuesFuncs, err := getAgentValuesFuncs(clients, opts, desc)
+	if err != nil {
+		return nil, err
+	}
+
+	installNamespaceFunc := CommonAgentInstallNamespaceFromDeploymentConfigFunc(clients.ADCGetter)
+
+	chartOptions := agent.AgentAddonOptions{
+		AddonName:             desc.Name,
+		Registration:          registrationOption,
+		AgentInstallNamespace: installNamespaceFunc,
+		HostedModeEnabled:     true,
+		SupportedConfigGVRs:   []schema.GroupVersionResource{utils.AddOnDeploymentConfigGVR},
+	}
+
+	// The overlay directory of the addon may be created after startup, so the addons are always
+	// rendered from the overlay when it is configured
+	if opts.ChartOverlayDir != "" {
+		overlay := newChartOverlayFS(desc.FS, filepath.Join(opts.ChartOverlayDir, desc.Name))
+
+		chartAddon, err := newChartAgentAddon(overlay, chartOptions, clients.ClusterClient, getValuesFuncs...)
+		if err != nil {
+			return nil, err
+		}
+
+		overlayAddon, err := newChartOverlayAgentAddon(chartAddon, overlay)
+		if err != nil {
+			return nil, err
+		}
+
+		return overlayAddon, nil
+	}
+
+	embeddedFS, ok := desc.FS.(embed.FS)
+	if !ok {
+		return newChartAgentAddon(desc.FS, chartOptions, clients.ClusterClient, getValuesFuncs...)
+	}
+
+	return addonfactory.NewAgentAddonFactory(desc.Name, embeddedFS, ChartDir).
+		WithConfigGVRs(utils.AddOnDeploymentConfigGVR).
+		WithGetValuesFuncs(getValuesFuncs...).
+		WithManagedClusterClient(clients.ClusterClient).
+		WithAgentRegistrationOption(registrationOption).
+		WithAgentInstallNamespace(installNamespaceFunc).
+		WithScheme(Scheme).
+		WithAgentHostedModeEnabledOption().
+		BuildHelmAgentAddon()
+}
+
+// AddAgents builds an agent addon for each descriptor and adds it to the manager. It returns an
+// error when a required addon can't be set up, while the optional addons are skipped.
+func AddAgents(
+	ctx context.Context,
+	mgr addonmanager.AddonManager,
+	controllerContext *controllercmd.ControllerContext,
+	opts AgentOptions,
+	descriptors ...*AgentDescriptor,
+) error {
+	clients, err := NewHubClients(ctx, controllerContext.KubeConfig)
+	if err != nil {
+		return err
+	}
+
+	if opts.HubAPIServer == "" {
+		hubAPIServer, err := GetHubAPIServer(ctx, clients.KubeClient, controllerContext.KubeConfig.Host)
+		if err != nil {
+			// The hub API server is only needed for the agents behind a proxy
+			hubAPIServer = controllerContext.KubeConfig.Host
+
+			log.Error(err, "failed to discover the hub API server, falling back to the controller's API server",
+				"hubAPIServer", hubAPIServer)
+		} else {
+			log.Info("Discovered the hub API server", "hubAPIServer", hubAPIServer)
+		}
+
+		opts.HubAPIServer = hubAPIServer
+	}
+
+	clients.HubAPIServer = opts.HubAPIServer
+
+	clients.HubCA, err = GetHubCA(ctx, clients.KubeClient, controllerContext.KubeConfig)
+	if err != nil {
+		// The hub CA is only published for information
+		log.Error(err, "failed to discover the hub CA")
+	}
+
+	var imageKeys []string
+
+	for _, desc := range descriptors {
+		if desc.ImageKey != "" {
+			imageKeys = append(imageKeys, desc.ImageKey)
+		}
+	}
+
+	if opts.ControllerConfigFile != "" {
+		config, err := LoadControllerConfig(opts.ControllerConfigFile, imageKeys)
+		if err != nil {
+			return err
+		}
+
+		SetControllerConfig(config)
+	}
+
+	clients.TLSProfile = NewHubTLSProfile(clients.KubeClient, clients.ConfigClient, controllerContext.OperatorNamespace)
+
+	// Start with the agent defaults if the hub TLS profile can't be read, and retry in Run
+	if _, err := clients.TLSProfile.Refresh(ctx); err != nil {
+		log.Error(err, "failed to read the hub TLS profile")
+	} else if profile := clients.TLSProfile.Get(); profile != nil {
+		log.Info("Using the hub TLS profile for the agents", "source", profile.Source,
+			"minVersion", profile.MinVersion, "cipherSuites", profile.CipherSuites)
+	}
+
+	var addonNames, tlsProfileAddons []string
+
+	for _, desc := range descriptors {
+		err := addAgent(ctx, mgr, controllerContext, clients, opts, desc)
+		if err != nil {
+			if !desc.Optional {
+				return err
+			}
+
+			log.Error(err, "Skipping the optional addon that couldn't be set up", "addon", desc.Name)
+			controllerContext.EventRecorder.Warningf("AddonSkipped",
+				"The optional %s addon was skipped because it couldn't be set up: %v", desc.Name, err)
+
+			continue
+		}
+
+		addonNames = append(addonNames, desc.Name)
+
+		if desc.HubController != nil {
+			go desc.HubController(ctx, mgr, clients)
+		}
+
+		if desc.TLSProfile {
+			tlsProfileAddons = append(tlsProfileAddons, desc.Name)
+		}
+	}
+
+	if err := watchPostRenderPatches(mgr, clients, addonNames); err != nil {
+		return err
+	}
+
+	go clients.TLSProfile.Run(ctx, func() {
+		triggerAddons(mgr, clients.AddonLister, tlsProfileAddons...)
+	})
+
+	if opts.ControllerConfigFile != "" {
+		go WatchControllerConfig(ctx, opts.ControllerConfigFile, imageKeys, func() {
+			triggerAddons(mgr, clients.AddonLister, addonNames...)
+		})
+	}
+
+	return nil
+}
+
+// getAgentValuesFuncs returns the values functions of the addon in increasing priority: the
+// addon-specific defaults, the values annotation, the AddOnDeploymentConfig and its customized
+// variables, and then the values mandated by the controller and the addon. The addons without an
+// agent only get the node placement and the customized variables of the AddOnDeploymentConfig,
+// and the values mandated by the addon.
+func getAgentValuesFuncs(
+	clients *HubClients, opts AgentOptions, desc *AgentDescriptor,
+) ([]addonfactory.GetValuesFunc, error) {
+	var getValuesFuncs []addonfactory.GetValuesFunc
+
+	if desc.GetValuesFuncs != nil {
+		getValuesFuncs = append(getValuesFuncs, desc.GetValuesFuncs(clients)...)
+	}
+
+	if desc.TLSProfile {
+		getValuesFuncs = append(getValuesFuncs, getHubTLSProfileValues(clients.TLSProfile))
+	}
+
+	adcValuesFuncs := []addonfactory.AddOnDeploymentConfigToValuesFunc{addonfactory.ToAddOnNodePlacementValues}
+
+	if !desc.NoAgent {
+		adcValuesFuncs = append(adcValuesFuncs,
+			addonfactory.ToAddOnResourceRequirementsValues,
+			getProxyConfigValuesFunc(opts.HubAPIServer),
+		)
+
+		getValuesFuncs = append(getValuesFuncs, addonfactory.GetValuesFromAddonAnnotation)
+	}
+
+	getValuesFuncs = append(getValuesFuncs,
+		addonfactory.GetAddOnDeploymentConfigValues(clients.ADCGetter, adcValuesFuncs...),
+	)
+
+	if desc.CustomizedVariableValues != nil {
+		getValuesFuncs = append(getValuesFuncs,
+			getCustomizedVariableValues(clients.ADCGetter, desc.CustomizedVariableValues))
+	}
+
+	if !desc.NoAgent {
+		getValuesFuncs = append(getValuesFuncs, MandateValues)
+	}
+
+	if desc.MandatedValuesFuncs != nil {
+		getValuesFuncs = append(getValuesFuncs, desc.MandatedValuesFuncs(clients)...)
+	}
+
+	if desc.ImageKey != "" {
+		defaultImage, err := getChartDefaultImage(desc.FS, desc.ImageKey)
+		if err != nil {
+			return nil, err
+		}
+
+		getValuesFuncs = append(getValuesFuncs,
+			getImageValues(clients.ADCGetter, desc.ImageEnvVar, desc.ImageKey, defaultImage))
+	}
+
+	return getValuesFuncs, nil
+}
+
+// addAgent builds the agent addon for the descriptor and adds it to the manager.
+func addAgent(
+	ctx context.Context,
+	mgr addonmanager.AddonManager,
+	controllerContext *controllercmd.ControllerContext,
+	clients *HubClients,
+	opts AgentOptions,
+	desc *AgentDescriptor,
+) error {
+	agentAddon, err := BuildAgentAddon(ctx, controllerContext, clients, opts, desc)
+	if err != nil {
+		return fmt.Errorf("failed getting the %v agent addon: %w", desc.Name, err)
+	}
+
+	// The chart overlay is scanned in the background, and the addon is rendered again when it changes
+	if overlayAddon, ok := agentAddon.(*chartOverlayAgentAddon); ok {
+		go overlayAddon.watch(ctx, func() {
+			triggerAddons(mgr, clients.AddonLister, desc.Name)
+		})
+	}
+
+	// The extra flags are appended before the patches, so that the patches can still change them,
+	// and the environment is set after, so that it uses the patched resource limits
+	postRenderFuncs := []PostRenderFunc{
+		getExtraArgsFunc(clients, desc), GetPostRenderPatchesFunc(clients), getAgentEnvFunc(clients, desc),
+	}
+
+	if desc.TLSProfile {
+		postRenderFuncs = append(postRenderFuncs, getTLSProfileConditionFunc(clients))
+	}
+
+	if desc.PostRenderFuncs != nil {
+		postRenderFuncs = append(postRenderFuncs, desc.PostRenderFuncs(clients)...)
+	}
+
+	agentAddon = &PolicyAgentAddon{
+		AgentAddon:      agentAddon,
+		PostRenderFuncs: postRenderFuncs,
+		AddonClient:     clients.AddonClient,
+	}
+
+	if desc.WrapAgent != nil {
+		agentAddon = desc.WrapAgent(agentAddon, mgr)
+	}
+
+	err = mgr.AddAgent(agentAddon)
+	if err != nil {
+		return fmt.Errorf("failed adding the %v agent addon to the manager: %w", desc.Name, err)
+	}
+
+	return nil
+}
+
+// getImageValues returns the image for the addon in the chart's global.imageOverrides at
+// imageKey. The image is chosen from the controller configuration or the environment variable
+// when it is set, and otherwise
+// from the values annotation or the chart's default, and then the registries of the
+// AddOnDeploymentConfig are applied to it so that it can be pulled from a mirror.
+func getImageValues(
+	adcGetter utils.AddOnDeploymentConfigGetter, envVar, imageKey, defaultImage string,
+) addonfactory.GetValuesFunc {
+	return func(
+		cluster *clusterv1.ManagedCluster,
+		addon *addonapiv1beta1.ManagedClusterAddOn,
+	) (addonfactory.Values, error) {
+		values := addonfactory.Values{}
+
+		img := GetControllerConfig().Images[imageKey]
+		if img == "" {
+			img = os.Getenv(envVar)
+		}
+
+		mandated := img != ""
+
+		if !mandated {
+			annotationValues, err := addonfactory.GetValuesFromAddonAnnotation(cluster, addon)
+			if err != nil {
+				return nil, err
+			}
+
+			img = defaultImage
+
+			annotationImage, err := chartutil.Values(annotationValues).PathValue("global.imageOverrides." + imageKey)
+			if override, ok := annotationImage.(string); err == nil && ok && override != "" {
+				img = override
+			}
+		}
+
+		var registries []addonapiv1beta1.ImageMirror
+
+		config, err := utils.GetDesiredAddOnDeploymentConfig(addon, adcGetter)
+		if err != nil {
+			return nil, err
+		}
+
+		if config != nil {
+			registries = config.Spec.Registries
+		}
+
+		if img == "" || (!mandated && len(registries) == 0) {
+			return values, nil
+		}
+
+		values["global"] = map[string]any{
+			"imageOverrides": map[string]any{
+				imageKey: addonfactory.OverrideImage(registries, img),
+			},
+		}
+
+		return values, nil
+	}
+}
+
+// getChartDefaultImage returns the default image in the chart's global.imageOverrides at
+// imageKey, or an empty string if there isn't one.
+func getChartDefaultImage(chartFS fs.FS, imageKey string) (string, error) {
+	rawValues, err := fs.ReadFile(chartFS, ChartDir+"/"+chartutil.ValuesfileName)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	values, err := chartutil.ReadValues(rawValues)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the chart values: %w", err)
+	}
+
+	img, err := values.PathValue("global.imageOverrides." + imageKey)
+	if err != nil {
+		return "", nil //nolint:nilerr // the chart doesn't have a default image
+	}
+
+	defaultImage, _ := img.(string)
+
+	return defaultImage, nil
+}
+
+// triggerAddons triggers the manager to render the ManagedClusterAddOns of the addons again on
+// every managed cluster.
+func triggerAddons(
+	mgr addonmanager.AddonManager, addonLister addonlistersv1alpha1.ManagedClusterAddOnLister, addonNames ...string,
+) {
+	if len(addonNames) == 0 {
+		return
+	}
+
+	addons, err := addonLister.List(labels.Everything())
+	if err != nil {
+		log.Error(err, "failed to list the ManagedClusterAddOns to render again")
+
+		return
+	}
+
+	for _, addon := range addons {
+		for _, addonName := range addonNames {
+			if addon.Name == addonName {
+				mgr.Trigger(addon.Namespace, addon.Name)
+			}
+		}
+	}
+}
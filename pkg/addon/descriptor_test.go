@@ -0,0 +1,297 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+type fakeADCGetter struct {
+	config *addonapiv1beta1.AddOnDeploymentConfig
+}
+
+func (f *fakeADCGetter) Get(_ context.Context, _, _ string) (*addonapiv1beta1.AddOnDeploymentConfig, error) {
+	return f.config, nil
+}
+
+// newTestAddon returns a ManagedClusterAddOn referencing an AddOnDeploymentConfig with the given spec.
+func newTestAddon(
+	spec addonapiv1beta1.AddOnDeploymentConfigSpec, annotations map[string]string,
+) (*addonapiv1beta1.ManagedClusterAddOn, utils.AddOnDeploymentConfigGetter) {
+	addon := &addonapiv1beta1.ManagedClusterAddOn{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-controller", Namespace: "cluster1", Annotations: annotations},
+		Status: addonapiv1beta1.ManagedClusterAddOnStatus{
+			ConfigReferences: []addonapiv1beta1.ConfigReference{{
+				ConfigGroupResource: addonapiv1beta1.ConfigGroupResource{
+					Group:    utils.AddOnDeploymentConfigGVR.Group,
+					Resource: utils.AddOnDeploymentConfigGVR.Resource,
+				},
+				DesiredConfig: &addonapiv1beta1.ConfigSpecHash{
+					ConfigReferent: addonapiv1beta1.ConfigReferent{Namespace: "cluster1", Name: "config"},
+					SpecHash:       "hash",
+				},
+			}},
+		},
+	}
+
+	config := &addonapiv1beta1.AddOnDeploymentConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "cluster1"},
+		Spec:       spec,
+	}
+
+	return addon, &fakeADCGetter{config: config}
+}
+
+func TestGetImageValues(t *testing.T) {
+	const defaultImage = "quay.io/open-cluster-management/my-controller:latest"
+
+	mirrors := addonapiv1beta1.AddOnDeploymentConfigSpec{
+		Registries: []addonapiv1beta1.ImageMirror{{
+			Source: "quay.io/open-cluster-management",
+			Mirror: "mirror.example.com/ocm",
+		}},
+	}
+
+	tests := map[string]struct {
+		envImage    string
+		configImage string
+		annotations map[string]string
+		spec        addonapiv1beta1.AddOnDeploymentConfigSpec
+		expected    string
+	}{
+		"no environment variable or registries": {
+			expected: "",
+		},
+		"environment variable": {
+			envImage: "quay.io/open-cluster-management/my-controller:v1",
+			expected: "quay.io/open-cluster-management/my-controller:v1",
+		},
+		"environment variable with registries": {
+			envImage: "quay.io/open-cluster-management/my-controller:v1",
+			spec:     mirrors,
+			expected: "mirror.example.com/ocm/my-controller:v1",
+		},
+		"controller configuration over the environment variable": {
+			envImage:    "quay.io/open-cluster-management/my-controller:v1",
+			configImage: "quay.io/open-cluster-management/my-controller:v3",
+			spec:        mirrors,
+			expected:    "mirror.example.com/ocm/my-controller:v3",
+		},
+		"chart default with registries": {
+			spec:     mirrors,
+			expected: "mirror.example.com/ocm/my-controller:latest",
+		},
+		"values annotation with registries": {
+			annotations: map[string]string{
+				"addon.open-cluster-management.io/values": `{"global":{"imageOverrides":` +
+					`{"my_controller":"quay.io/open-cluster-management/my-controller:v2"}}}`,
+			},
+			spec:     mirrors,
+			expected: "mirror.example.com/ocm/my-controller:v2",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("MY_CONTROLLER_IMAGE", test.envImage)
+
+			SetControllerConfig(&ControllerConfig{Images: map[string]string{"my_controller": test.configImage}})
+			t.Cleanup(func() { SetControllerConfig(&ControllerConfig{}) })
+
+			addon, getter := newTestAddon(test.spec, test.annotations)
+
+			values, err := getImageValues(getter, "MY_CONTROLLER_IMAGE", "my_controller", defaultImage)(
+				&clusterv1.ManagedCluster{}, addon)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if test.expected == "" {
+				if len(values) != 0 {
+					t.Fatalf("expected no values, got: %v", values)
+				}
+
+				return
+			}
+
+			img, err := chartutil.Values(values).PathValue("global.imageOverrides.my_controller")
+			if err != nil || img != test.expected {
+				t.Fatalf("expected the image %s, got: %v", test.expected, values)
+			}
+		})
+	}
+}
+
+func TestGetChartDefaultImage(t *testing.T) {
+	chartFS := fstest.MapFS{
+		ChartDir + "/values.yaml": {Data: []byte("global:\n  imageOverrides:\n    my_controller: my-controller:latest\n")},
+	}
+
+	img, err := getChartDefaultImage(chartFS, "my_controller")
+	if err != nil || img != "my-controller:latest" {
+		t.Fatalf("expected the chart's default image, got: %q, %v", img, err)
+	}
+
+	img, err = getChartDefaultImage(chartFS, "other_controller")
+	if err != nil || img != "" {
+		t.Fatalf("expected no image, got: %q, %v", img, err)
+	}
+}
+
+func TestGetAgentValuesFuncsPriority(t *testing.T) {
+	addon, getter := newTestAddon(addonapiv1beta1.AddOnDeploymentConfigSpec{
+		CustomizedVariables: []addonapiv1beta1.CustomizedVariable{
+			{Name: "hubGroup", Value: "from-config"},
+			{Name: "logLevel", Value: "2"},
+		},
+	}, nil)
+
+	addonValues := func(value string) addonfactory.GetValuesFunc {
+		return func(_ *clusterv1.ManagedCluster, _ *addonapiv1beta1.ManagedClusterAddOn) (addonfactory.Values, error) {
+			return addonfactory.Values{"hubGroup": value, "logLevel": value}, nil
+		}
+	}
+
+	desc := &AgentDescriptor{
+		Name: "my-controller",
+		FS:   fstest.MapFS{},
+		GetValuesFuncs: func(_ *HubClients) []addonfactory.GetValuesFunc {
+			return []addonfactory.GetValuesFunc{addonValues("default")}
+		},
+		CustomizedVariableValues: addonfactory.ToAddOnCustomizedVariableValues,
+		MandatedValuesFuncs: func(_ *HubClients) []addonfactory.GetValuesFunc {
+			return []addonfactory.GetValuesFunc{func(
+				_ *clusterv1.ManagedCluster, _ *addonapiv1beta1.ManagedClusterAddOn,
+			) (addonfactory.Values, error) {
+				return addonfactory.Values{"hubGroup": "mandated"}, nil
+			}}
+		},
+	}
+
+	getValuesFuncs, err := getAgentValuesFuncs(&HubClients{ADCGetter: getter}, AgentOptions{}, desc)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	values := addonfactory.Values{}
+
+	for _, getValuesFunc := range getValuesFuncs {
+		funcValues, err := getValuesFunc(&clusterv1.ManagedCluster{}, addon)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		values = addonfactory.MergeValues(values, funcValues)
+	}
+
+	if values["hubGroup"] != "mandated" {
+		t.Fatalf("expected the mandated value to override the customized variable, got: %v", values["hubGroup"])
+	}
+
+	if values["logLevel"] != "2" {
+		t.Fatalf("expected the customized variable to override the addon default, got: %v", values["logLevel"])
+	}
+}
+
+func TestGetAgentValuesFuncsNoAgent(t *testing.T) {
+	addon, getter := newTestAddon(addonapiv1beta1.AddOnDeploymentConfigSpec{
+		NodePlacement: &addonapiv1beta1.NodePlacement{NodeSelector: map[string]string{"role": "infra"}},
+		ProxyConfig:   addonapiv1beta1.ProxyConfig{HTTPSProxy: "https://proxy.example.com:3128"},
+		ResourceRequirements: []addonapiv1beta1.ContainerResourceRequirements{{
+			ContainerID: "*:*:*",
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+			},
+		}},
+	}, map[string]string{"addon.open-cluster-management.io/values": `{"fromAnnotation":"true"}`})
+
+	now := metav1.Now()
+	addon.DeletionTimestamp = &now
+
+	tests := map[string]struct {
+		noAgent     bool
+		expectedSet []string
+		expectedNot []string
+	}{
+		"agent": {
+			expectedSet: []string{
+				"global.nodeSelector", "global.proxyConfig", "global.resourceRequirements", "fromAnnotation",
+				"uninstallationAnnotation",
+			},
+		},
+		"no agent": {
+			noAgent:     true,
+			expectedSet: []string{"global.nodeSelector"},
+			expectedNot: []string{
+				"global.proxyConfig", "global.resourceRequirements", "fromAnnotation", "uninstallationAnnotation",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			desc := &AgentDescriptor{Name: "my-controller", FS: fstest.MapFS{}, NoAgent: test.noAgent}
+
+			getValuesFuncs, err := getAgentValuesFuncs(&HubClients{ADCGetter: getter}, AgentOptions{}, desc)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			values := addonfactory.Values{}
+
+			for _, getValuesFunc := range getValuesFuncs {
+				funcValues, err := getValuesFunc(&clusterv1.ManagedCluster{}, addon)
+				if err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+
+				values = addonfactory.MergeValues(values, funcValues)
+			}
+
+			// The values are maps, which PathValue doesn't return
+			hasValue := func(path string) bool {
+				parent, key := "", path
+				if i := strings.LastIndex(path, "."); i >= 0 {
+					parent, key = path[:i], path[i+1:]
+				}
+
+				table := chartutil.Values(values)
+				if parent != "" {
+					var err error
+
+					if table, err = table.Table(parent); err != nil {
+						return false
+					}
+				}
+
+				_, ok := table[key]
+
+				return ok
+			}
+
+			for _, path := range test.expectedSet {
+				if !hasValue(path) {
+					t.Fatalf("expected the value %s to be set, got: %v", path, values)
+				}
+			}
+
+			for _, path := range test.expectedNot {
+				if hasValue(path) {
+					t.Fatalf("expected the value %s not to be set, got: %v", path, values)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package addon
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+// The Kubernetes distributions recognized by GetClusterVendor.
+const (
+	DistributionOpenShift  = "OpenShift"
+	DistributionMicroShift = "MicroShift"
+	DistributionEKS        = "EKS"
+	DistributionAKS        = "AKS"
+	DistributionGKE        = "GKE"
+	DistributionIKS        = "IKS"
+	DistributionK3s        = "K3s"
+	DistributionRKE2       = "RKE2"
+
+	productClaimName = "product.open-cluster-management.io"
+)
+
+// productDistributions maps the lowercase values of the product cluster claim and of the vendor
+// label to the distributions. The managed OpenShift offerings are all OpenShift, since the charts
+// rely on OpenShift features like the service CA.
+var productDistributions = map[string]string{
+	"openshift":          DistributionOpenShift,
+	"openshiftdedicated": DistributionOpenShift,
+	"rosa":               DistributionOpenShift,
+	"aro":                DistributionOpenShift,
+	"roks":               DistributionOpenShift,
+	"microshift":         DistributionMicroShift,
+	"eks":                DistributionEKS,
+	"aks":                DistributionAKS,
+	"gke":                DistributionGKE,
+	"iks":                DistributionIKS,
+	"k3s":                DistributionK3s,
+	"rke2":               DistributionRKE2,
+}
+
+// versionDistributions maps markers in the Kubernetes version of a cluster, like the
+// "v1.30.4+k3s1" version of k3s, to the distributions.
+var versionDistributions = []struct {
+	marker       string
+	distribution string
+}{
+	{"+k3s", DistributionK3s},
+	{"+rke2", DistributionRKE2},
+	{"-eks-", DistributionEKS},
+	{"-gke.", DistributionGKE},
+	{"+IKS", DistributionIKS},
+}
+
+// GetClusterVendor determines the vendor of the cluster based on the labels
+// and cluster claims. The product cluster claim and the vendor label are used
+// first, and then the Kubernetes version reported by the cluster. An unknown
+// product claim is returned as is, and an empty string means it is unknown.
+func GetClusterVendor(cluster *clusterv1.ManagedCluster) string {
+	var product string
+
+	for _, cc := range cluster.Status.ClusterClaims {
+		if cc.Name == productClaimName {
+			product = cc.Value
+
+			break
+		}
+	}
+
+	if distribution, ok := productDistributions[strings.ToLower(product)]; ok {
+		return distribution
+	}
+
+	// Don't just use the value in the label, it might be something like "auto-detect"
+	if distribution, ok := productDistributions[strings.ToLower(cluster.Labels["vendor"])]; ok {
+		return distribution
+	}
+
+	for _, vd := range versionDistributions {
+		if strings.Contains(cluster.Status.Version.Kubernetes, vd.marker) {
+			return vd.distribution
+		}
+	}
+
+	if product != "" && !strings.EqualFold(product, "Other") {
+		return product
+	}
+
+	return ""
+}
+
+// DistributionProfile adjusts the default values of the addon charts for a Kubernetes
+// distribution. The AddOnDeploymentConfig overrides them.
+type DistributionProfile struct {
+	// PrometheusEnabled enables the metrics by default.
+	PrometheusEnabled bool
+	// Tolerations are added to the tolerations of the charts.
+	Tolerations []corev1.Toleration
+	// SeccompProfileType sets the seccomp profile of the pods, regardless of the Kubernetes version.
+	SeccompProfileType corev1.SeccompProfileType
+	// Footprint is the default footprint of the agents, like FootprintEdge.
+	Footprint string
+}
+
+// distributionProfiles are the profiles of the distributions that need other defaults.
+var distributionProfiles = map[string]DistributionProfile{
+	DistributionOpenShift: {
+		PrometheusEnabled: true,
+	},
+	DistributionMicroShift: {
+		Footprint: FootprintEdge,
+	},
+	DistributionK3s: {
+		Footprint: FootprintEdge,
+	},
+	DistributionEKS: {
+		SeccompProfileType: corev1.SeccompProfileTypeRuntimeDefault,
+	},
+	DistributionAKS: {
+		// The system node pools of AKS are commonly tainted to only run critical addons
+		Tolerations: []corev1.Toleration{{
+			Key:      "CriticalAddonsOnly",
+			Operator: corev1.TolerationOpExists,
+		}},
+		SeccompProfileType: corev1.SeccompProfileTypeRuntimeDefault,
+	},
+	DistributionGKE: {
+		SeccompProfileType: corev1.SeccompProfileTypeRuntimeDefault,
+	},
+	DistributionIKS: {
+		SeccompProfileType: corev1.SeccompProfileTypeRuntimeDefault,
+	},
+	DistributionRKE2: {
+		SeccompProfileType: corev1.SeccompProfileTypeRuntimeDefault,
+	},
+}
+
+// GetDistributionProfile returns the profile of the distribution, which is empty when the
+// distribution keeps the chart defaults.
+func GetDistributionProfile(distribution string) DistributionProfile {
+	return distributionProfiles[distribution]
+}
@@ -0,0 +1,87 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+func TestGetClusterVendor(t *testing.T) {
+	tests := map[string]struct {
+		vendorLabel string
+		product     string
+		kubeVersion string
+		expected    string
+	}{
+		"OpenShift product claim":            {product: "OpenShift", expected: DistributionOpenShift},
+		"ROSA is OpenShift":                  {product: "ROSA", vendorLabel: "OpenShift", expected: DistributionOpenShift},
+		"OpenShift vendor label":             {vendorLabel: "OpenShift", expected: DistributionOpenShift},
+		"auto-detect vendor label":           {vendorLabel: "auto-detect", expected: ""},
+		"MicroShift product claim":           {product: "MicroShift", expected: DistributionMicroShift},
+		"EKS product claim":                  {product: "EKS", expected: DistributionEKS},
+		"AKS vendor label":                   {vendorLabel: "AKS", product: "Other", expected: DistributionAKS},
+		"GKE Kubernetes version":             {kubeVersion: "v1.30.5-gke.1014001", expected: DistributionGKE},
+		"k3s Kubernetes version":             {product: "Other", kubeVersion: "v1.30.4+k3s1", expected: DistributionK3s},
+		"RKE2 Kubernetes version":            {kubeVersion: "v1.29.8+rke2r1", expected: DistributionRKE2},
+		"unknown product claim is kept":      {product: "SomeKube", expected: "SomeKube"},
+		"Other product claim is unknown":     {product: "Other", kubeVersion: "v1.30.0", expected: ""},
+		"upstream Kubernetes has no profile": {kubeVersion: "v1.30.0", expected: ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{}},
+				Status: clusterv1.ManagedClusterStatus{
+					Version: clusterv1.ManagedClusterVersion{Kubernetes: test.kubeVersion},
+				},
+			}
+
+			if test.vendorLabel != "" {
+				cluster.Labels["vendor"] = test.vendorLabel
+			}
+
+			if test.product != "" {
+				cluster.Status.ClusterClaims = []clusterv1.ManagedClusterClaim{
+					{Name: "platform.open-cluster-management.io", Value: "AWS"},
+					{Name: productClaimName, Value: test.product},
+				}
+			}
+
+			if vendor := GetClusterVendor(cluster); vendor != test.expected {
+				t.Fatalf("expected the vendor %q, got: %q", test.expected, vendor)
+			}
+		})
+	}
+}
+
+func TestSetDistributionProfile(t *testing.T) {
+	cv := &CommonValues{}
+	cv.SetDistributionProfile(GetDistributionProfile(DistributionOpenShift))
+
+	if !*cv.PrometheusConfig.Enabled || cv.DistributionProfile != nil || cv.GlobalValues != nil {
+		t.Fatalf("expected only the metrics to be enabled on OpenShift, got: %+v", cv)
+	}
+
+	cv = &CommonValues{}
+	cv.SetDistributionProfile(GetDistributionProfile(DistributionAKS))
+
+	if *cv.PrometheusConfig.Enabled || cv.DistributionProfile == nil ||
+		len(cv.DistributionProfile.Tolerations) != 1 || cv.DistributionProfile.SeccompProfileType != "RuntimeDefault" {
+		t.Fatalf("expected the AKS tolerations and seccomp profile, got: %+v", cv.DistributionProfile)
+	}
+
+	if GetDistributionProfile(DistributionK3s).Footprint != FootprintEdge {
+		t.Fatal("expected the edge footprint on k3s")
+	}
+
+	cv = &CommonValues{}
+	cv.SetDistributionProfile(GetDistributionProfile(""))
+
+	if *cv.PrometheusConfig.Enabled || cv.DistributionProfile != nil || cv.GlobalValues != nil {
+		t.Fatalf("expected the chart defaults for an unknown distribution, got: %+v", cv)
+	}
+}
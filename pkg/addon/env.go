@@ -0,0 +1,162 @@
+package addon
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ExtraEnvVariable is the AddOnDeploymentConfig customized variable with the YAML or JSON map
+	// of the environment variables added to the agent container.
+	ExtraEnvVariable = "extraEnv"
+	// GoMaxProcsVariable is the AddOnDeploymentConfig customized variable that, when true, sets
+	// GOMAXPROCS in the agent container from its CPU limit.
+	GoMaxProcsVariable = "goMaxProcsFromLimits"
+
+	// goMemLimitPercent is the percentage of the memory limit used for GOMEMLIMIT, leaving room
+	// for the memory that the Go runtime doesn't manage.
+	goMemLimitPercent = 90
+)
+
+// agentContainer returns the agent container, which is the first container of the agent Deployment
+// of the chart, or nil when the Deployment wasn't rendered.
+func agentContainer(objects []runtime.Object, chartName string) *corev1.Container {
+	deployment := agentDeployment(objects, chartName)
+	if deployment == nil {
+		return nil
+	}
+
+	return &deployment.Spec.Template.Spec.Containers[0]
+}
+
+// parseExtraEnv parses the extraEnv customized variable, sorted by name. It is a YAML or JSON
+// map, so that the values can contain commas.
+func parseExtraEnv(value string) ([]corev1.EnvVar, error) {
+	pairs := map[string]string{}
+
+	if err := yaml.UnmarshalStrict([]byte(value), &pairs); err != nil {
+		return nil, fmt.Errorf("invalid extraEnv map: %w", err)
+	}
+
+	env := make([]corev1.EnvVar, 0, len(pairs))
+
+	for _, name := range slices.Sorted(maps.Keys(pairs)) {
+		if errs := validation.IsEnvVarName(name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid environment variable name '%s': %v", name, errs)
+		}
+
+		env = append(env, corev1.EnvVar{Name: name, Value: pairs[name]})
+	}
+
+	return env, nil
+}
+
+// addExtraEnv adds the environment variables to the container, which must not already have them.
+func addExtraEnv(container *corev1.Container, env []corev1.EnvVar) error {
+	for _, envVar := range env {
+		if hasEnv(container, envVar.Name) {
+			return fmt.Errorf("the environment variable %s is already set by the chart", envVar.Name)
+		}
+	}
+
+	container.Env = append(container.Env, env...)
+
+	return nil
+}
+
+// setGoRuntimeEnv sets GOMEMLIMIT from the memory limit of the container, and GOMAXPROCS from its
+// CPU limit when goMaxProcs is true, unless they are already set.
+func setGoRuntimeEnv(container *corev1.Container, goMaxProcs bool) {
+	if memory, ok := container.Resources.Limits[corev1.ResourceMemory]; ok && !hasEnv(container, "GOMEMLIMIT") {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  "GOMEMLIMIT",
+			Value: strconv.FormatInt(memory.Value()*goMemLimitPercent/100, 10),
+		})
+	}
+
+	if cpu, ok := container.Resources.Limits[corev1.ResourceCPU]; ok && goMaxProcs && !hasEnv(container, "GOMAXPROCS") {
+		// Round up, so that a fractional limit still gets one thread
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  "GOMAXPROCS",
+			Value: strconv.FormatInt(max((cpu.MilliValue()+999)/1000, 1), 10),
+		})
+	}
+}
+
+func hasEnv(container *corev1.Container, name string) bool {
+	return slices.ContainsFunc(container.Env, func(envVar corev1.EnvVar) bool { return envVar.Name == name })
+}
+
+// getAgentEnvFunc returns a post-render function adding the environment variables of the extraEnv
+// customized variable to the agent container, and then, when the descriptor sets GoRuntimeEnv, the
+// Go runtime environment variables derived from its effective resource limits. It runs after the
+// patches, which may change the limits. An invalid extraEnv is logged and ignored, like other
+// customized variables, but the render fails when the agent Deployment isn't found.
+func getAgentEnvFunc(clients *HubClients, desc *AgentDescriptor) PostRenderFunc {
+	return func(
+		_ context.Context,
+		_ *clusterv1.ManagedCluster,
+		addon *addonapiv1beta1.ManagedClusterAddOn,
+		objects []runtime.Object,
+	) ([]runtime.Object, error) {
+		// The addons without an agent only deploy secrets
+		if desc.NoAgent {
+			return objects, nil
+		}
+
+		variables, err := GetCustomizedVariables(clients.ADCGetter, addon)
+		if err != nil {
+			return nil, err
+		}
+
+		extraEnv, hasExtraEnv := variables[ExtraEnvVariable]
+		if !hasExtraEnv && !desc.GoRuntimeEnv {
+			return objects, nil
+		}
+
+		container := agentContainer(objects, desc.Name)
+		if container == nil {
+			return nil, fmt.Errorf("the %s deployment wasn't rendered to set its environment", desc.Name)
+		}
+
+		if hasExtraEnv {
+			env, err := parseExtraEnv(extraEnv)
+			if err == nil {
+				err = addExtraEnv(container, env)
+			}
+
+			if err != nil {
+				log.Error(err, "ignoring the extraEnv customized variable", "namespace", addon.Namespace,
+					"name", addon.Name, "value", extraEnv)
+			}
+		}
+
+		if !desc.GoRuntimeEnv {
+			return objects, nil
+		}
+
+		goMaxProcs := false
+
+		if value, ok := variables[GoMaxProcsVariable]; ok {
+			goMaxProcs, err = strconv.ParseBool(value)
+			if err != nil {
+				log.Error(err, "ignoring the goMaxProcsFromLimits customized variable", "namespace", addon.Namespace,
+					"name", addon.Name, "value", value)
+			}
+		}
+
+		setGoRuntimeEnv(container, goMaxProcs)
+
+		return objects, nil
+	}
+}
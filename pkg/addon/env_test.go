@@ -0,0 +1,153 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+func TestParseExtraEnv(t *testing.T) {
+	env, err := parseExtraEnv(`{"TZ": "UTC", "NO_PROXY": "example.com,.svc"}`)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	expected := []corev1.EnvVar{{Name: "NO_PROXY", Value: "example.com,.svc"}, {Name: "TZ", Value: "UTC"}}
+	if !slices.Equal(env, expected) {
+		t.Fatalf("expected the sorted environment variables, got: %v", env)
+	}
+
+	env, err = parseExtraEnv("GODEBUG: madvdontneed=1")
+	if err != nil || !slices.Equal(env, []corev1.EnvVar{{Name: "GODEBUG", Value: "madvdontneed=1"}}) {
+		t.Fatalf("expected the YAML environment variables, got: %v, %v", env, err)
+	}
+
+	for _, value := range []string{"TZ=UTC", `{"1TZ": "UTC"}`, `{"TZ": ["UTC"]}`} {
+		if _, err := parseExtraEnv(value); err == nil {
+			t.Fatalf("expected an error for the extraEnv %q", value)
+		}
+	}
+}
+
+func TestAddExtraEnv(t *testing.T) {
+	container := &corev1.Container{Env: []corev1.EnvVar{{Name: "POD_NAME"}}}
+
+	if err := addExtraEnv(container, []corev1.EnvVar{{Name: "POD_NAME", Value: "other"}}); err == nil {
+		t.Fatal("expected an error for an environment variable set by the chart")
+	}
+
+	if err := addExtraEnv(container, []corev1.EnvVar{{Name: "TZ", Value: "UTC"}}); err != nil || len(container.Env) != 2 {
+		t.Fatalf("expected the environment variable to be added, got: %v, %v", container.Env, err)
+	}
+}
+
+func TestSetGoRuntimeEnv(t *testing.T) {
+	newContainer := func() *corev1.Container {
+		return &corev1.Container{
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse("512Mi"),
+					corev1.ResourceCPU:    resource.MustParse("1500m"),
+				},
+			},
+		}
+	}
+
+	container := newContainer()
+	setGoRuntimeEnv(container, false)
+
+	if !slices.Equal(container.Env, []corev1.EnvVar{{Name: "GOMEMLIMIT", Value: "483183820"}}) {
+		t.Fatalf("expected GOMEMLIMIT at 90%% of the memory limit, got: %v", container.Env)
+	}
+
+	container = newContainer()
+	container.Env = []corev1.EnvVar{{Name: "GOMEMLIMIT", Value: "off"}}
+	setGoRuntimeEnv(container, true)
+
+	expected := []corev1.EnvVar{{Name: "GOMEMLIMIT", Value: "off"}, {Name: "GOMAXPROCS", Value: "2"}}
+	if !slices.Equal(container.Env, expected) {
+		t.Fatalf("expected GOMEMLIMIT to be kept and GOMAXPROCS to be rounded up, got: %v", container.Env)
+	}
+
+	container = &corev1.Container{}
+	setGoRuntimeEnv(container, true)
+
+	if len(container.Env) != 0 {
+		t.Fatalf("expected no environment variables without limits, got: %v", container.Env)
+	}
+}
+
+func TestGetAgentEnvFunc(t *testing.T) {
+	tests := map[string]struct {
+		goRuntimeEnv bool
+		expected     []corev1.EnvVar
+	}{
+		"Go agent": {
+			goRuntimeEnv: true,
+			expected:     []corev1.EnvVar{{Name: "TZ", Value: "UTC"}, {Name: "GOMEMLIMIT", Value: "483183820"}},
+		},
+		// External agents may not be built with Go
+		"other agent": {expected: []corev1.EnvVar{{Name: "TZ", Value: "UTC"}}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			addon, getter := newTestAddon(addonapiv1beta1.AddOnDeploymentConfigSpec{
+				CustomizedVariables: []addonapiv1beta1.CustomizedVariable{
+					{Name: ExtraEnvVariable, Value: "TZ: UTC"},
+				},
+			}, nil)
+
+			deployment := &appsv1.Deployment{
+				// Renamed by a fullnameOverride, so only the chart label matches the chart
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "my-fullname-override",
+					Labels: map[string]string{"chart": "my-controller-0.1.0"},
+				},
+				Spec: appsv1.DeploymentSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{
+								Name: "manager",
+								Resources: corev1.ResourceRequirements{
+									Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+								},
+							}},
+						},
+					},
+				},
+			}
+
+			desc := &AgentDescriptor{Name: "my-controller", GoRuntimeEnv: test.goRuntimeEnv}
+
+			_, err := getAgentEnvFunc(&HubClients{ADCGetter: getter}, desc)(
+				context.TODO(), &clusterv1.ManagedCluster{}, addon, []runtime.Object{deployment},
+			)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			env := deployment.Spec.Template.Spec.Containers[0].Env
+			if !slices.Equal(env, test.expected) {
+				t.Fatalf("expected the environment variables %v, got: %v", test.expected, env)
+			}
+
+			_, err = getAgentEnvFunc(&HubClients{ADCGetter: getter}, desc)(
+				context.TODO(), &clusterv1.ManagedCluster{}, addon, []runtime.Object{},
+			)
+			if err == nil {
+				t.Fatal("expected an error when the agent deployment isn't rendered")
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package addon
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ExternalAddonMetadataFile is the metadata file of an addon loaded from disk.
+	ExternalAddonMetadataFile = "addon.yaml"
+	// HubPermissionsDir is the directory of the hub permission templates in an addon's filesystem.
+	HubPermissionsDir = "manifests/hubpermissions"
+)
+
+// externalAddonMetadata is the content of the metadata file of an addon loaded from disk.
+type externalAddonMetadata struct {
+	// Name is the name of the addon. It defaults to the name of the addon's directory.
+	Name string `json:"name,omitempty"`
+	// ImageEnvVar and ImageKey mandate an image from the controller's environment,
+	// the same as for the built-in addons.
+	ImageEnvVar string `json:"imageEnvVar,omitempty"`
+	ImageKey    string `json:"imageKey,omitempty"`
+	// HubPermissionsForAddon binds the hub permissions to the group for the entire addon
+	// instead of the cluster-specific group.
+	HubPermissionsForAddon bool `json:"hubPermissionsForAddon,omitempty"`
+}
+
+// LoadExternalAddons reads the addons in the subdirectories of the given directory.
+// Each addon directory has the same layout as the built-in addons, with its chart
+// in ChartDir and its hub permission templates in HubPermissionsDir, along with an
+// ExternalAddonMetadataFile. When any addon fails to load, no descriptors are returned
+// and the errors of all the addons are joined, so that the controller doesn't start
+// with a broken addons directory.
+func LoadExternalAddons(dir string) ([]*AgentDescriptor, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the addons directory %s: %w", dir, err)
+	}
+
+	descriptors := []*AgentDescriptor{}
+
+	var aggregateErr error
+
+	for _, entry := range entries {
+		// Skip files and the hidden directories created by mounted ConfigMaps and Secrets
+		if !entry.IsDir() || entry.Name()[0] == '.' {
+			continue
+		}
+
+		desc, err := loadExternalAddon(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			aggregateErr = errors.Join(aggregateErr, fmt.Errorf("failed to load the addon in %s: %w", entry.Name(), err))
+
+			continue
+		}
+
+		descriptors = append(descriptors, desc)
+	}
+
+	if aggregateErr != nil {
+		return nil, aggregateErr
+	}
+
+	return descriptors, nil
+}
+
+func loadExternalAddon(dir string) (*AgentDescriptor, error) {
+	addonFS := os.DirFS(dir)
+
+	rawMetadata, err := fs.ReadFile(addonFS, ExternalAddonMetadataFile)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := externalAddonMetadata{}
+
+	if err := yaml.UnmarshalStrict(rawMetadata, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ExternalAddonMetadataFile, err)
+	}
+
+	if metadata.Name == "" {
+		metadata.Name = filepath.Base(dir)
+	}
+
+	if errs := validation.IsDNS1123Label(metadata.Name); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid addon name '%s': %v", metadata.Name, errs)
+	}
+
+	if (metadata.ImageEnvVar == "") != (metadata.ImageKey == "") {
+		return nil, errors.New("imageEnvVar and imageKey must be set together")
+	}
+
+	if _, err := fs.Stat(addonFS, ChartDir+"/Chart.yaml"); err != nil {
+		return nil, fmt.Errorf("the addon has no chart: %w", err)
+	}
+
+	hubPermissionFiles := []string{}
+
+	permissionEntries, err := fs.ReadDir(addonFS, HubPermissionsDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	for _, entry := range permissionEntries {
+		if !entry.IsDir() && (filepath.Ext(entry.Name()) == ".yaml" || filepath.Ext(entry.Name()) == ".yml") {
+			hubPermissionFiles = append(hubPermissionFiles, HubPermissionsDir+"/"+entry.Name())
+		}
+	}
+
+	// Apply the templates in a predictable order, so Roles can be created before their bindings
+	sort.Strings(hubPermissionFiles)
+
+	return &AgentDescriptor{
+		Name:                   metadata.Name,
+		FS:                     addonFS,
+		HubPermissionFiles:     hubPermissionFiles,
+		HubPermissionsForAddon: metadata.HubPermissionsForAddon,
+		GetValuesFuncs: func(clients *HubClients) []addonfactory.GetValuesFunc {
+			return []addonfactory.GetValuesFunc{getExternalAddonValues(clients)}
+		},
+		CustomizedVariableValues: getExternalAddonValuesFromCustomizedVariables,
+		ImageEnvVar:              metadata.ImageEnvVar,
+		ImageKey:                 metadata.ImageKey,
+	}, nil
+}
+
+func getExternalAddonSkeletonValues() CommonValues {
+	return CommonValues{
+		BaseValues: BaseValues{
+			GlobalValues: &GlobalValues{
+				ImagePullPolicy: corev1.PullIfNotPresent,
+				NetworkPolicies: &NetworkPolicies{
+					Enabled: GetNetworkPoliciesEnabled(),
+				},
+			},
+		},
+	}
+}
+
+// getExternalAddonValues sets the common values for an addon loaded from disk.
+func getExternalAddonValues(clients *HubClients) addonfactory.GetValuesFunc {
+	return func(
+		cluster *clusterv1.ManagedCluster, addon *addonapiv1beta1.ManagedClusterAddOn,
+	) (addonfactory.Values, error) {
+		userValues := getExternalAddonSkeletonValues()
+
+		err := userValues.SetCommonValues(cluster, addon, clients)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := userValues.SetCommonValuesFromAnnotations(addon); err != nil {
+			log.Error(err, "failed to set common values from annotations")
+		}
+
+		return addonfactory.JsonStructToValues(userValues)
+	}
+}
+
+// getExternalAddonValuesFromCustomizedVariables sets the common values from the
+// customized variables for an addon loaded from disk. Since the controller doesn't
+// know the addon's own variables, the unknown ones are passed to the chart as is.
+func getExternalAddonValuesFromCustomizedVariables(
+	config addonapiv1beta1.AddOnDeploymentConfig,
+) (addonfactory.Values, error) {
+	userValues := getExternalAddonSkeletonValues()
+
+	userValuesMap, err := userValues.SetCommonValuesFromCustomizedVariables(config)
+	if err != nil {
+		log.Error(err, "error setting common addon values from customized variables")
+	}
+
+	values, err := addonfactory.JsonStructToValues(userValues)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range userValuesMap {
+		values[key] = value
+	}
+
+	return values, nil
+}
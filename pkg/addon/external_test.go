@@ -0,0 +1,172 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+const testDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .Release.Name }}
+  namespace: {{ .Release.Namespace }}
+spec:
+  replicas: {{ .Values.replicas }}
+  selector:
+    matchLabels:
+      app: {{ .Release.Name }}
+  template:
+    metadata:
+      labels:
+        app: {{ .Release.Name }}
+    spec:
+      containers:
+      - name: controller
+        image: {{ .Values.image }}
+        args:
+        - --cluster-name={{ .Values.clusterName }}
+`
+
+func writeTestAddon(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadExternalAddons(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestAddon(t, filepath.Join(dir, "my-controller"), map[string]string{
+		"addon.yaml": "imageEnvVar: MY_CONTROLLER_IMAGE\nimageKey: my_controller\n",
+		"manifests/managedclusterchart/Chart.yaml":         "apiVersion: v2\nname: my-controller\nversion: 0.1.0\n",
+		"manifests/managedclusterchart/values.yaml":        "replicas: 1\nimage: my-controller:latest\n",
+		"manifests/managedclusterchart/templates/dep.yaml": testDeployment,
+		"manifests/hubpermissions/rolebinding.yaml":        "kind: RoleBinding",
+		"manifests/hubpermissions/role.yaml":               "kind: Role",
+	})
+
+	descriptors, err := LoadExternalAddons(dir)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(descriptors) != 1 {
+		t.Fatalf("expected one addon, got: %d", len(descriptors))
+	}
+
+	desc := descriptors[0]
+
+	if desc.Name != "my-controller" || desc.ImageKey != "my_controller" {
+		t.Fatalf("unexpected descriptor: %+v", desc)
+	}
+
+	expectedFiles := []string{"manifests/hubpermissions/role.yaml", "manifests/hubpermissions/rolebinding.yaml"}
+	if len(desc.HubPermissionFiles) != 2 ||
+		desc.HubPermissionFiles[0] != expectedFiles[0] || desc.HubPermissionFiles[1] != expectedFiles[1] {
+		t.Fatalf("expected hub permission files %v, got: %v", expectedFiles, desc.HubPermissionFiles)
+	}
+
+	t.Run("renders the chart", func(t *testing.T) {
+		setReplicas := func(
+			_ *clusterv1.ManagedCluster, _ *addonapiv1beta1.ManagedClusterAddOn,
+		) (addonfactory.Values, error) {
+			return addonfactory.Values{"replicas": 2}, nil
+		}
+
+		chartAddon, err := newChartAgentAddon(desc.FS, agent.AgentAddonOptions{AddonName: desc.Name}, nil, setReplicas)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		cluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+		mcao := &addonapiv1beta1.ManagedClusterAddOn{
+			ObjectMeta: metav1.ObjectMeta{Name: desc.Name, Namespace: "cluster1"},
+		}
+
+		objects, err := chartAddon.Manifests(context.TODO(), cluster, mcao)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if len(objects) != 1 {
+			t.Fatalf("expected one object, got: %d", len(objects))
+		}
+
+		deployment, ok := objects[0].(*appsv1.Deployment)
+		if !ok {
+			t.Fatalf("expected a Deployment, got: %T", objects[0])
+		}
+
+		if deployment.Namespace != addonfactory.AddonDefaultInstallNamespace {
+			t.Fatalf("expected the default install namespace, got: %q", deployment.Namespace)
+		}
+
+		if *deployment.Spec.Replicas != 2 {
+			t.Fatalf("expected the values function to set the replicas, got: %d", *deployment.Spec.Replicas)
+		}
+
+		if args := deployment.Spec.Template.Spec.Containers[0].Args; args[0] != "--cluster-name=cluster1" {
+			t.Fatalf("expected the built-in cluster name value, got: %v", args)
+		}
+	})
+}
+
+func TestLoadExternalAddonsInvalid(t *testing.T) {
+	tests := map[string]map[string]string{
+		"missing metadata": {
+			"manifests/managedclusterchart/Chart.yaml": "apiVersion: v2\nname: c\nversion: 0.1.0\n",
+		},
+		"missing chart": {
+			"addon.yaml": "name: my-controller\n",
+		},
+		"unknown metadata field": {
+			"addon.yaml": "name: my-controller\nimage: foo\n",
+			"manifests/managedclusterchart/Chart.yaml": "apiVersion: v2\nname: c\nversion: 0.1.0\n",
+		},
+		"invalid name": {
+			"addon.yaml": "name: My_Controller\n",
+			"manifests/managedclusterchart/Chart.yaml": "apiVersion: v2\nname: c\nversion: 0.1.0\n",
+		},
+	}
+
+	for name, files := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestAddon(t, filepath.Join(dir, "addon"), files)
+			writeTestAddon(t, filepath.Join(dir, "valid-addon"), map[string]string{
+				"addon.yaml": "{}\n",
+				"manifests/managedclusterchart/Chart.yaml": "apiVersion: v2\nname: c\nversion: 0.1.0\n",
+			})
+
+			descriptors, err := LoadExternalAddons(dir)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			if descriptors != nil {
+				t.Fatalf("expected no addons to be loaded, got: %v", descriptors)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package addon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+// ExtraArgsVariable is the AddOnDeploymentConfig customized variable with the whitespace-separated
+// flags appended to the agent container, like "--evaluation-backoff=30". Only the flags in the
+// ExtraArgs allowlist of the addon are accepted.
+const ExtraArgsVariable = "extraArgs"
+
+// parseExtraArgs returns the flags of the extraArgs customized variable, or an error when one of
+// them isn't in the allowlist of flag names.
+func parseExtraArgs(value string, allowlist []string) ([]string, error) {
+	args := strings.Fields(value)
+
+	var aggregateErr error
+
+	for _, arg := range args {
+		name, _, _ := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+
+		if !strings.HasPrefix(arg, "--") || !slices.Contains(allowlist, name) {
+			aggregateErr = errors.Join(aggregateErr, fmt.Errorf("the flag '%s' is not allowed, expected one of %v",
+				arg, allowlist))
+		}
+	}
+
+	if aggregateErr != nil {
+		return nil, aggregateErr
+	}
+
+	return args, nil
+}
+
+// agentDeployment returns the agent Deployment of the chart, or nil when it wasn't rendered. It is
+// found by its chart label, which starts with the chart name, rather than by its name, since the
+// name changes with the fullnameOverride and nameOverride values.
+func agentDeployment(objects []runtime.Object, chartName string) *appsv1.Deployment {
+	for _, obj := range objects {
+		deployment, ok := obj.(*appsv1.Deployment)
+		if ok && strings.HasPrefix(deployment.Labels["chart"], chartName+"-") &&
+			len(deployment.Spec.Template.Spec.Containers) > 0 {
+			return deployment
+		}
+	}
+
+	return nil
+}
+
+// appendExtraArgs appends the flags to the first container of the agent Deployment of the chart,
+// which must not already have them.
+func appendExtraArgs(objects []runtime.Object, chartName string, args []string) error {
+	deployment := agentDeployment(objects, chartName)
+	if deployment == nil {
+		return fmt.Errorf("the %s deployment wasn't rendered", chartName)
+	}
+
+	container := &deployment.Spec.Template.Spec.Containers[0]
+
+	for _, arg := range args {
+		name, _, _ := strings.Cut(arg, "=")
+
+		if slices.ContainsFunc(container.Args, func(existing string) bool {
+			return existing == name || strings.HasPrefix(existing, name+"=")
+		}) {
+			return fmt.Errorf("the flag '%s' is already set by the chart", name)
+		}
+	}
+
+	container.Args = append(container.Args, args...)
+
+	return nil
+}
+
+// getExtraArgsFunc returns a post-render function appending the flags of the extraArgs customized
+// variable to the agent container, which is the first container of the Deployment of the chart named
+// after the addon. Invalid flags are logged and the variable is ignored, like other customized
+// variables, but the render fails when the agent Deployment isn't found.
+func getExtraArgsFunc(clients *HubClients, desc *AgentDescriptor) PostRenderFunc {
+	return func(
+		_ context.Context,
+		_ *clusterv1.ManagedCluster,
+		addon *addonapiv1beta1.ManagedClusterAddOn,
+		objects []runtime.Object,
+	) ([]runtime.Object, error) {
+		config, err := utils.GetDesiredAddOnDeploymentConfig(addon, clients.ADCGetter)
+		if err != nil {
+			return nil, err
+		}
+
+		config = withControllerConfigDefaults(config)
+		if config == nil {
+			return objects, nil
+		}
+
+		for _, variable := range config.Spec.CustomizedVariables {
+			if variable.Name != ExtraArgsVariable {
+				continue
+			}
+
+			args, err := parseExtraArgs(variable.Value, desc.ExtraArgs)
+			if err != nil {
+				log.Error(err, "ignoring the extraArgs customized variable", "namespace", addon.Namespace,
+					"name", addon.Name, "value", variable.Value)
+
+				continue
+			}
+
+			if len(args) == 0 {
+				continue
+			}
+
+			if agentDeployment(objects, desc.Name) == nil {
+				return nil, fmt.Errorf("the %s deployment wasn't rendered to append the extraArgs", desc.Name)
+			}
+
+			if err := appendExtraArgs(objects, desc.Name, args); err != nil {
+				log.Error(err, "ignoring the extraArgs customized variable", "namespace", addon.Namespace,
+					"name", addon.Name, "value", variable.Value)
+			}
+		}
+
+		return objects, nil
+	}
+}
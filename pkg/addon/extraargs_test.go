@@ -0,0 +1,113 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+func TestParseExtraArgs(t *testing.T) {
+	allowlist := []string{"evaluation-backoff", "decryption-concurrency"}
+
+	args, err := parseExtraArgs(" --evaluation-backoff=30\n--decryption-concurrency=5 ", allowlist)
+	if err != nil || !slices.Equal(args, []string{"--evaluation-backoff=30", "--decryption-concurrency=5"}) {
+		t.Fatalf("expected the allowed flags, got: %v, %v", args, err)
+	}
+
+	for _, value := range []string{"--evaluation-backoff=30 --kubeconfig=/tmp/config", "evaluation-backoff=30"} {
+		if _, err := parseExtraArgs(value, allowlist); err == nil {
+			t.Fatalf("expected an error for the extraArgs %q", value)
+		}
+	}
+}
+
+func TestAppendExtraArgs(t *testing.T) {
+	newObjects := func() []runtime.Object {
+		return []runtime.Object{
+			&appsv1.Deployment{
+				// The name is set by a fullnameOverride, so only the chart label matches the chart
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "custom-controller",
+					Namespace: "agent",
+					Labels:    map[string]string{"chart": "controller-0.1.0"},
+				},
+				Spec: appsv1.DeploymentSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{
+								{Name: "manager", Args: []string{"--leader-elect=false"}},
+								{Name: "sidecar"},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	objects := newObjects()
+
+	if err := appendExtraArgs(objects, "controller", []string{"--evaluation-backoff=30"}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	containers := objects[0].(*appsv1.Deployment).Spec.Template.Spec.Containers
+	if !slices.Equal(containers[0].Args, []string{"--leader-elect=false", "--evaluation-backoff=30"}) ||
+		len(containers[1].Args) != 0 {
+		t.Fatalf("expected the flag to be appended to the first container, got: %v", containers)
+	}
+
+	if err := appendExtraArgs(newObjects(), "controller", []string{"--leader-elect"}); err == nil {
+		t.Fatal("expected an error for a flag set by the chart")
+	}
+
+	if err := appendExtraArgs(newObjects(), "other", []string{"--evaluation-backoff=30"}); err == nil {
+		t.Fatal("expected an error when the deployment isn't rendered")
+	}
+}
+
+func TestGetExtraArgsFunc(t *testing.T) {
+	addon, getter := newTestAddon(addonapiv1beta1.AddOnDeploymentConfigSpec{
+		CustomizedVariables: []addonapiv1beta1.CustomizedVariable{
+			{Name: ExtraArgsVariable, Value: "--evaluation-backoff=30"},
+		},
+	}, nil)
+
+	desc := &AgentDescriptor{Name: "my-controller", ExtraArgs: []string{"evaluation-backoff"}}
+	postRender := getExtraArgsFunc(&HubClients{ADCGetter: getter}, desc)
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "my-fullname-override",
+			Labels: map[string]string{"chart": "my-controller-0.1.0"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "manager"}}},
+			},
+		},
+	}
+
+	_, err := postRender(context.TODO(), &clusterv1.ManagedCluster{}, addon, []runtime.Object{deployment})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if args := deployment.Spec.Template.Spec.Containers[0].Args; !slices.Equal(args, []string{"--evaluation-backoff=30"}) {
+		t.Fatalf("expected the flag to be appended to the renamed deployment, got: %v", args)
+	}
+
+	_, err = postRender(context.TODO(), &clusterv1.ManagedCluster{}, addon, []runtime.Object{})
+	if err == nil {
+		t.Fatal("expected an error when the agent deployment isn't rendered")
+	}
+}
@@ -0,0 +1,90 @@
+package addon
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+)
+
+const (
+	// FootprintVariable is the AddOnDeploymentConfig customized variable choosing the footprint
+	// of the agents, overriding the footprint of the distribution profile.
+	FootprintVariable = "footprint"
+	// FootprintStandard keeps the chart defaults.
+	FootprintStandard = "standard"
+	// FootprintEdge reduces the footprint of the agents for edge devices and single node clusters.
+	FootprintEdge = "edge"
+)
+
+// Probes contains the probe configuration values for the addon chart.
+type Probes struct {
+	PeriodSeconds    int32 `json:"periodSeconds,omitempty"`
+	TimeoutSeconds   int32 `json:"timeoutSeconds,omitempty"`
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+	// StartupFailureThreshold times PeriodSeconds is the time given to the controller to start.
+	StartupFailureThreshold int32 `json:"startupFailureThreshold,omitempty"`
+}
+
+// SetEdgeFootprint sets the values of the edge footprint. The agents request less memory,
+// evaluate one policy at a time, deploy no monitoring objects, and the probes give slow nodes
+// more time.
+func (cv *CommonValues) SetEdgeFootprint() {
+	if cv.GlobalValues == nil {
+		cv.GlobalValues = &GlobalValues{}
+	}
+
+	cv.GlobalValues.ResourceRequirements = []ResourceRequirements{{
+		ContainerIDRegex: "^.+:.+:.+$",
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")},
+			Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+		},
+	}}
+
+	prometheusEnabled := false
+	cv.prometheusConfig().Enabled = &prometheusEnabled
+
+	cv.EvaluationConcurrency = 1
+	cv.ClientQPS = 15
+	cv.ClientBurst = 23
+
+	cv.Probes = &Probes{
+		PeriodSeconds:           20,
+		TimeoutSeconds:          5,
+		FailureThreshold:        6,
+		StartupFailureThreshold: 60,
+	}
+}
+
+// getFootprint returns the footprint from the AddOnDeploymentConfig of the addon, or otherwise
+// from the distribution profile of the cluster where the agents run.
+func getFootprint(
+	clients *HubClients, addon *addonapiv1beta1.ManagedClusterAddOn, profile DistributionProfile,
+) (string, error) {
+	config, err := utils.GetDesiredAddOnDeploymentConfig(addon, clients.ADCGetter)
+	if err != nil {
+		return "", err
+	}
+
+	if config = withControllerConfigDefaults(config); config != nil {
+		for _, variable := range config.Spec.CustomizedVariables {
+			if variable.Name != FootprintVariable {
+				continue
+			}
+
+			switch variable.Value {
+			case FootprintStandard, FootprintEdge:
+				return variable.Value, nil
+			default:
+				log.Error(fmt.Errorf("expected %s or %s", FootprintStandard, FootprintEdge),
+					"invalid footprint customized variable, falling back to the distribution profile",
+					"namespace", config.Namespace, "name", config.Name, "value", variable.Value)
+			}
+		}
+	}
+
+	return profile.Footprint, nil
+}
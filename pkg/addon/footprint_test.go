@@ -0,0 +1,75 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"testing"
+
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+)
+
+func TestGetFootprint(t *testing.T) {
+	edgeProfile := GetDistributionProfile(DistributionMicroShift)
+
+	tests := map[string]struct {
+		footprint string
+		profile   DistributionProfile
+		expected  string
+	}{
+		"no variable or profile":              {expected: ""},
+		"edge distribution profile":           {profile: edgeProfile, expected: FootprintEdge},
+		"edge variable":                       {footprint: "edge", expected: FootprintEdge},
+		"standard variable overrides profile": {footprint: "standard", profile: edgeProfile, expected: FootprintStandard},
+		"invalid variable uses the profile":   {footprint: "tiny", profile: edgeProfile, expected: FootprintEdge},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			spec := addonapiv1beta1.AddOnDeploymentConfigSpec{}
+			if test.footprint != "" {
+				spec.CustomizedVariables = []addonapiv1beta1.CustomizedVariable{
+					{Name: FootprintVariable, Value: test.footprint},
+				}
+			}
+
+			addon, getter := newTestAddon(spec, nil)
+
+			footprint, err := getFootprint(&HubClients{ADCGetter: getter}, addon, test.profile)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if footprint != test.expected {
+				t.Fatalf("expected the footprint %q, got: %q", test.expected, footprint)
+			}
+		})
+	}
+}
+
+func TestSetEdgeFootprint(t *testing.T) {
+	cv := &CommonValues{}
+	cv.SetDistributionProfile(GetDistributionProfile(DistributionOpenShift))
+	cv.SetEdgeFootprint()
+
+	if *cv.PrometheusConfig.Enabled {
+		t.Fatal("expected the edge footprint to disable the metrics")
+	}
+
+	if cv.EvaluationConcurrency != 1 || cv.Probes == nil || cv.Probes.StartupFailureThreshold != 60 {
+		t.Fatalf("expected the edge concurrency and probes, got: %d, %+v", cv.EvaluationConcurrency, cv.Probes)
+	}
+
+	limit := cv.GlobalValues.ResourceRequirements[0].Resources.Limits.Memory().String()
+	if limit != "256Mi" {
+		t.Fatalf("expected the edge memory limit to be 256Mi, got: %s", limit)
+	}
+
+	// The annotations are set after the footprint and override it
+	if err := cv.SetEvaluationConcurrency("3"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if cv.EvaluationConcurrency != 3 {
+		t.Fatalf("expected the evaluation concurrency to be overridden, got: %d", cv.EvaluationConcurrency)
+	}
+}
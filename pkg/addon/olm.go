@@ -0,0 +1,51 @@
+package addon
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+const (
+	// OLMLabel is the ManagedCluster label set on the hub to the global operators namespace of the
+	// Operator Lifecycle Manager on the managed cluster, or to OLMAbsent when it isn't installed.
+	OLMLabel = "policy.open-cluster-management.io/olm"
+	// OLMAbsent is the value of the label when OLM isn't installed.
+	OLMAbsent = "none"
+
+	openShiftOperatorsNamespace = "openshift-operators"
+)
+
+// OLM describes the Operator Lifecycle Manager on a managed cluster.
+type OLM struct {
+	Installed bool
+	// GlobalOperatorsNamespace is the namespace of the operators watching all namespaces.
+	GlobalOperatorsNamespace string
+}
+
+// GetClusterOLM returns the OLM of the managed cluster set by the OLMLabel, or the OLM shipped with
+// OpenShift. OLM isn't detected automatically, since the managed clusters don't report it, so the
+// other clusters must be labeled manually, including MicroShift where it is an optional package. It
+// returns false for them when the label isn't set.
+func GetClusterOLM(cluster *clusterv1.ManagedCluster) (OLM, bool) {
+	if value, ok := cluster.Labels[OLMLabel]; ok {
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.EqualFold(value, OLMAbsent):
+			return OLM{}, true
+		case len(validation.IsDNS1123Label(value)) == 0:
+			return OLM{Installed: true, GlobalOperatorsNamespace: value}, true
+		default:
+			log.Info("Ignoring the invalid OLM label of the managed cluster", "cluster", cluster.Name,
+				"label", OLMLabel, "value", value)
+		}
+	}
+
+	if cluster.Labels["openshiftVersion-major"] == "4" || GetClusterVendor(cluster) == DistributionOpenShift {
+		return OLM{Installed: true, GlobalOperatorsNamespace: openShiftOperatorsNamespace}, true
+	}
+
+	return OLM{}, false
+}
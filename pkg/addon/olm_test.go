@@ -0,0 +1,68 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+func TestGetClusterOLM(t *testing.T) {
+	tests := map[string]struct {
+		labels        map[string]string
+		claims        map[string]string
+		expected      OLM
+		expectedKnown bool
+	}{
+		"OpenShift 4": {
+			labels:        map[string]string{"openshiftVersion-major": "4"},
+			expected:      OLM{Installed: true, GlobalOperatorsNamespace: "openshift-operators"},
+			expectedKnown: true,
+		},
+		"OpenShift product claim": {
+			claims:        map[string]string{productClaimName: "ROSA"},
+			expected:      OLM{Installed: true, GlobalOperatorsNamespace: "openshift-operators"},
+			expectedKnown: true,
+		},
+		"MicroShift is unknown": {
+			claims: map[string]string{productClaimName: "MicroShift"},
+		},
+		"OLM label on Kubernetes": {
+			labels:        map[string]string{OLMLabel: "operators"},
+			expected:      OLM{Installed: true, GlobalOperatorsNamespace: "operators"},
+			expectedKnown: true,
+		},
+		"OLM label on MicroShift": {
+			labels:        map[string]string{OLMLabel: "openshift-operators"},
+			claims:        map[string]string{productClaimName: "MicroShift"},
+			expected:      OLM{Installed: true, GlobalOperatorsNamespace: "openshift-operators"},
+			expectedKnown: true,
+		},
+		"absent OLM label overrides OpenShift": {
+			labels:        map[string]string{OLMLabel: "none", "openshiftVersion-major": "4"},
+			expectedKnown: true,
+		},
+		"invalid OLM label is ignored": {
+			labels: map[string]string{OLMLabel: "Not_A_Namespace"},
+		},
+		"Kubernetes is unknown": {},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1", Labels: test.labels}}
+
+			for claim, value := range test.claims {
+				cluster.Status.ClusterClaims = append(cluster.Status.ClusterClaims,
+					clusterv1.ManagedClusterClaim{Name: claim, Value: value})
+			}
+
+			olm, known := GetClusterOLM(cluster)
+			if olm != test.expected || known != test.expectedKnown {
+				t.Fatalf("expected %+v (known: %v), got: %+v (known: %v)", test.expected, test.expectedKnown, olm, known)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package addon
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+// ChartOverriddenConditionType is the ManagedClusterAddOn condition reporting the chart
+// files replaced by the chart overlay directory.
+const ChartOverriddenConditionType = "ChartOverridden"
+
+// chartOverlayPollInterval is the interval at which the chart overlay directory is scanned for
+// changes.
+const chartOverlayPollInterval = 10 * time.Second
+
+// chartOverlayFS serves the files of the chart in ChartDir from the overlay directory
+// when a file with the same relative path exists there, and from the base filesystem
+// otherwise. Directory listings come from the base filesystem, so only files that
+// already exist in the chart can be replaced.
+type chartOverlayFS struct {
+	base       fs.FS
+	overlayDir string
+}
+
+// newChartOverlayFS returns a filesystem where the files in the overlay directory replace
+// the matching files in the chart of the base filesystem.
+func newChartOverlayFS(base fs.FS, overlayDir string) *chartOverlayFS {
+	return &chartOverlayFS{base: base, overlayDir: overlayDir}
+}
+
+// Open implements fs.FS.
+func (o *chartOverlayFS) Open(name string) (fs.File, error) {
+	if rel, ok := strings.CutPrefix(name, ChartDir+"/"); ok && fs.ValidPath(name) {
+		file, err := os.Open(filepath.Join(o.overlayDir, filepath.FromSlash(rel)))
+		if err == nil {
+			info, err := file.Stat()
+			if err == nil && info.Mode().IsRegular() {
+				return file, nil
+			}
+
+			_ = file.Close()
+		}
+	}
+
+	return o.base.Open(name)
+}
+
+// overriddenFiles returns the sorted paths, relative to the chart, of the chart files
+// replaced by the overlay directory, and a digest of their content to detect changes. A missing
+// overlay directory doesn't override any file.
+func (o *chartOverlayFS) overriddenFiles() ([]string, string, error) {
+	overridden := []string{}
+	digest := sha256.New()
+
+	err := walkOverlayDir(o.overlayDir, "", map[string]bool{}, func(rel, path string) error {
+		info, err := fs.Stat(o.base, ChartDir+"/"+rel)
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		overridden = append(overridden, rel)
+
+		// The files are walked in lexical order, so the digest only changes with the files
+		digest.Write([]byte(rel))
+		digest.Write([]byte{0})
+		digest.Write(content)
+		digest.Write([]byte{0})
+
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) && !isDir(o.overlayDir) {
+		err = nil
+	}
+
+	return overridden, hex.EncodeToString(digest.Sum(nil)), err
+}
+
+// walkOverlayDir calls visit in lexical order with the slash-separated path relative to the
+// overlay directory and the path of each regular file under dir. Unlike filepath.WalkDir, it
+// follows the symbolic links, since a mounted ConfigMap with items links its directories to the
+// hidden ..data directory. The hidden files and directories are skipped, and each directory is
+// only walked once.
+func walkOverlayDir(dir, rel string, walked map[string]bool, visit func(rel, path string) error) error {
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+
+	if walked[realDir] {
+		return nil
+	}
+
+	walked[realDir] = true
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		entryRel := entry.Name()
+
+		if rel != "" {
+			entryRel = rel + "/" + entry.Name()
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			// A dangling symbolic link, such as during a ConfigMap update
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+
+			return err
+		}
+
+		switch {
+		case info.IsDir():
+			err = walkOverlayDir(path, entryRel, walked, visit)
+		case info.Mode().IsRegular():
+			err = visit(entryRel, path)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// chartOverlayAgentAddon renders the chart with the files replaced by the overlay directory,
+// and reports them in the status of each ManagedClusterAddOn it renders. The overlay directory
+// is scanned in the background rather than on each render, and the chart is reloaded when the
+// overridden files change.
+type chartOverlayAgentAddon struct {
+	*chartAgentAddon
+	overlay *chartOverlayFS
+
+	lock       sync.RWMutex
+	overridden []string
+	digest     string
+}
+
+// newChartOverlayAgentAddon returns the addon rendering the chart of the overlay filesystem,
+// with the files currently overridden.
+func newChartOverlayAgentAddon(
+	chartAddon *chartAgentAddon, overlay *chartOverlayFS,
+) (*chartOverlayAgentAddon, error) {
+	overridden, digest, err := overlay.overriddenFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	oa := &chartOverlayAgentAddon{
+		chartAgentAddon: chartAddon,
+		overlay:         overlay,
+		overridden:      overridden,
+		digest:          digest,
+	}
+
+	oa.logOverrides()
+
+	return oa, nil
+}
+
+// Manifests overrides the AgentAddon.Manifests method to report the overridden chart files.
+func (oa *chartOverlayAgentAddon) Manifests(
+	ctx context.Context,
+	cluster *clusterv1.ManagedCluster,
+	addon *addonapiv1beta1.ManagedClusterAddOn,
+) ([]runtime.Object, error) {
+	objects, err := oa.chartAgentAddon.Manifests(ctx, cluster, addon)
+	if err != nil {
+		return nil, err
+	}
+
+	overridden := oa.overriddenFiles()
+
+	if len(overridden) == 0 {
+		RemoveAddonCondition(ctx, ChartOverriddenConditionType)
+	} else {
+		SetAddonCondition(ctx, metav1.Condition{
+			Type:    ChartOverriddenConditionType,
+			Status:  metav1.ConditionTrue,
+			Reason:  "ChartOverlay",
+			Message: "Chart files replaced by the chart overlay directory: " + strings.Join(overridden, ", "),
+		})
+	}
+
+	return objects, nil
+}
+
+// overriddenFiles returns the chart files overridden when the overlay directory was last scanned.
+func (oa *chartOverlayAgentAddon) overriddenFiles() []string {
+	oa.lock.RLock()
+	defer oa.lock.RUnlock()
+
+	return oa.overridden
+}
+
+// watch scans the overlay directory periodically until the context is canceled. When the
+// overridden files change, it reloads the chart and calls onChange so that the addon is
+// rendered again.
+func (oa *chartOverlayAgentAddon) watch(ctx context.Context, onChange func()) {
+	wait.UntilWithContext(ctx, func(_ context.Context) {
+		if oa.refresh() {
+			onChange()
+		}
+	}, chartOverlayPollInterval)
+}
+
+// refresh scans the overlay directory and reloads the chart when the overridden files changed.
+// It returns whether they changed. On errors, the current chart is kept.
+func (oa *chartOverlayAgentAddon) refresh() bool {
+	overridden, digest, err := oa.overlay.overriddenFiles()
+	if err != nil {
+		log.Error(err, "failed to read the chart overlay directory, keeping the current chart",
+			"addon", oa.options.AddonName, "overlayDir", oa.overlay.overlayDir)
+
+		return false
+	}
+
+	oa.lock.RLock()
+	unchanged := digest == oa.digest
+	oa.lock.RUnlock()
+
+	if unchanged {
+		return false
+	}
+
+	if err := oa.reloadChart(); err != nil {
+		log.Error(err, "failed to load the overridden chart, keeping the current chart",
+			"addon", oa.options.AddonName, "overlayDir", oa.overlay.overlayDir)
+
+		return false
+	}
+
+	oa.lock.Lock()
+	oa.overridden = overridden
+	oa.digest = digest
+	oa.lock.Unlock()
+
+	oa.logOverrides()
+
+	return true
+}
+
+// logOverrides logs each overridden chart file.
+func (oa *chartOverlayAgentAddon) logOverrides() {
+	addonName := oa.options.AddonName
+	overridden := oa.overriddenFiles()
+
+	if len(overridden) == 0 {
+		log.Info("No chart files are overridden by the chart overlay directory",
+			"addon", addonName, "overlayDir", oa.overlay.overlayDir)
+
+		return
+	}
+
+	for _, file := range overridden {
+		log.Info("Chart file overridden by the chart overlay directory",
+			"addon", addonName, "file", file, "overlayDir", oa.overlay.overlayDir)
+	}
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+
+	return err == nil && info.IsDir()
+}
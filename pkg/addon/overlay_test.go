@@ -0,0 +1,305 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+func TestChartOverlayFS(t *testing.T) {
+	base := fstest.MapFS{
+		ChartDir + "/Chart.yaml":         {Data: []byte("apiVersion: v2\nname: c\nversion: 0.1.0\n")},
+		ChartDir + "/values.yaml":        {Data: []byte("replicas: 1\nimage: my-controller:latest\n")},
+		ChartDir + "/templates/dep.yaml": {Data: []byte(testDeployment)},
+	}
+
+	overlayDir := t.TempDir()
+
+	writeTestAddon(t, filepath.Join(overlayDir, "my-controller"), map[string]string{
+		"values.yaml":           "replicas: 3\nimage: my-controller:hotfix\n",
+		"templates/extra.yaml":  "kind: ConfigMap",
+		"..data/values.yaml":    "replicas: 5",
+		"templates/ignored.txt": "not in the chart",
+	})
+
+	overlay := newChartOverlayFS(base, filepath.Join(overlayDir, "my-controller"))
+
+	overridden, _, err := overlay.overriddenFiles()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if !slices.Equal(overridden, []string{"values.yaml"}) {
+		t.Fatalf("expected only values.yaml to be overridden, got: %v", overridden)
+	}
+
+	chartAddon, err := newChartAgentAddon(overlay, agent.AgentAddonOptions{AddonName: "my-controller"}, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	cluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+	mcao := &addonapiv1beta1.ManagedClusterAddOn{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-controller", Namespace: "cluster1"},
+	}
+
+	objects, err := chartAddon.Manifests(context.TODO(), cluster, mcao)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(objects) != 1 {
+		t.Fatalf("expected only the chart's Deployment, got: %d objects", len(objects))
+	}
+
+	deployment, ok := objects[0].(*appsv1.Deployment)
+	if !ok {
+		t.Fatalf("expected a Deployment, got: %T", objects[0])
+	}
+
+	if *deployment.Spec.Replicas != 3 {
+		t.Fatalf("expected the overridden replicas, got: %d", *deployment.Spec.Replicas)
+	}
+
+	t.Run("missing addon directory", func(t *testing.T) {
+		overlay := newChartOverlayFS(base, filepath.Join(overlayDir, "other-controller"))
+
+		overridden, _, err := overlay.overriddenFiles()
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if len(overridden) != 0 {
+			t.Fatalf("expected no overridden files, got: %v", overridden)
+		}
+	})
+}
+
+func TestChartOverlayAgentAddonRefresh(t *testing.T) {
+	base := fstest.MapFS{
+		ChartDir + "/Chart.yaml":         {Data: []byte("apiVersion: v2\nname: c\nversion: 0.1.0\n")},
+		ChartDir + "/values.yaml":        {Data: []byte("replicas: 1\nimage: my-controller:latest\n")},
+		ChartDir + "/templates/dep.yaml": {Data: []byte(testDeployment)},
+	}
+
+	overlayDir := t.TempDir()
+	overlay := newChartOverlayFS(base, overlayDir)
+
+	chartAddon, err := newChartAgentAddon(overlay, agent.AgentAddonOptions{AddonName: "my-controller"}, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	overlayAddon, err := newChartOverlayAgentAddon(chartAddon, overlay)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if overlayAddon.refresh() {
+		t.Fatal("expected no change without overridden files")
+	}
+
+	render := func() int32 {
+		t.Helper()
+
+		ctx, conditions := WithAddonConditions(context.TODO())
+		cluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+		mcao := &addonapiv1beta1.ManagedClusterAddOn{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-controller", Namespace: "cluster1"},
+		}
+
+		objects, err := overlayAddon.Manifests(ctx, cluster, mcao)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		overridden := meta.IsStatusConditionTrue(
+			conditions.Apply(nil), ChartOverriddenConditionType,
+		)
+		if overridden != (len(overlayAddon.overriddenFiles()) != 0) {
+			t.Fatalf("expected the ChartOverridden condition to match the overridden files %v",
+				overlayAddon.overriddenFiles())
+		}
+
+		deployment, ok := objects[0].(*appsv1.Deployment)
+		if !ok {
+			t.Fatalf("expected a Deployment, got: %T", objects[0])
+		}
+
+		return *deployment.Spec.Replicas
+	}
+
+	if replicas := render(); replicas != 1 {
+		t.Fatalf("expected the chart replicas, got: %d", replicas)
+	}
+
+	writeTestAddon(t, overlayDir, map[string]string{"values.yaml": "replicas: 3\nimage: my-controller:hotfix\n"})
+
+	// The overlay directory isn't read on render
+	if replicas := render(); replicas != 1 {
+		t.Fatalf("expected the cached chart replicas until the overlay is scanned, got: %d", replicas)
+	}
+
+	if !overlayAddon.refresh() {
+		t.Fatal("expected a change once values.yaml is overridden")
+	}
+
+	if replicas := render(); replicas != 3 {
+		t.Fatalf("expected the overridden replicas, got: %d", replicas)
+	}
+
+	writeTestAddon(t, overlayDir, map[string]string{"values.yaml": "replicas: 4\nimage: my-controller:hotfix\n"})
+
+	if !overlayAddon.refresh() {
+		t.Fatal("expected a change when the content of values.yaml changes")
+	}
+
+	if overlayAddon.refresh() {
+		t.Fatal("expected no change when the overlay is unchanged")
+	}
+
+	if replicas := render(); replicas != 4 {
+		t.Fatalf("expected the updated replicas, got: %d", replicas)
+	}
+}
+
+// writeConfigMapVolume writes the files like a mounted ConfigMap with items: in a timestamped
+// directory linked by ..data, with the top-level files and directories linked to ..data.
+func writeConfigMapVolume(t *testing.T, dir, timestamp string, files map[string]string) {
+	t.Helper()
+
+	writeTestAddon(t, filepath.Join(dir, timestamp), files)
+
+	dataLink := filepath.Join(dir, "..data")
+	tmpLink := filepath.Join(dir, "..data_tmp")
+
+	if err := os.Symlink(timestamp, tmpLink); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Rename(tmpLink, dataLink); err != nil {
+		t.Fatal(err)
+	}
+
+	for name := range files {
+		top, _, _ := strings.Cut(name, "/")
+
+		err := os.Symlink(filepath.Join("..data", top), filepath.Join(dir, top))
+		if err != nil && !errors.Is(err, fs.ErrExist) {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestChartOverlayConfigMapVolume(t *testing.T) {
+	base := fstest.MapFS{
+		ChartDir + "/Chart.yaml":         {Data: []byte("apiVersion: v2\nname: c\nversion: 0.1.0\n")},
+		ChartDir + "/values.yaml":        {Data: []byte("replicas: 1\nimage: my-controller:latest\n")},
+		ChartDir + "/templates/dep.yaml": {Data: []byte(testDeployment)},
+	}
+
+	overlayDir := t.TempDir()
+	overlay := newChartOverlayFS(base, overlayDir)
+
+	// The ConfigMap items mount the template in a linked directory
+	writeConfigMapVolume(t, overlayDir, "..2026_10_18_10_00_00.1", map[string]string{
+		"templates/dep.yaml": strings.Replace(testDeployment, "{{ .Values.replicas }}", "2", 1),
+	})
+
+	chartAddon, err := newChartAgentAddon(overlay, agent.AgentAddonOptions{AddonName: "my-controller"}, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	overlayAddon, err := newChartOverlayAgentAddon(chartAddon, overlay)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if overridden := overlayAddon.overriddenFiles(); !slices.Equal(overridden, []string{"templates/dep.yaml"}) {
+		t.Fatalf("expected the linked template to be overridden, got: %v", overridden)
+	}
+
+	// The ConfigMap is updated by switching ..data to a new timestamped directory
+	writeConfigMapVolume(t, overlayDir, "..2026_10_18_10_05_00.2", map[string]string{
+		"templates/dep.yaml": strings.Replace(testDeployment, "{{ .Values.replicas }}", "5", 1),
+	})
+
+	if !overlayAddon.refresh() {
+		t.Fatal("expected a change when the linked template changes")
+	}
+
+	cluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+	mcao := &addonapiv1beta1.ManagedClusterAddOn{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-controller", Namespace: "cluster1"},
+	}
+
+	objects, err := overlayAddon.Manifests(context.TODO(), cluster, mcao)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	deployment, ok := objects[0].(*appsv1.Deployment)
+	if !ok || *deployment.Spec.Replicas != 5 {
+		t.Fatalf("expected the updated linked template, got: %v", objects[0])
+	}
+}
+
+func TestChartOverlayCreatedLater(t *testing.T) {
+	base := fstest.MapFS{
+		ChartDir + "/Chart.yaml":         {Data: []byte("apiVersion: v2\nname: c\nversion: 0.1.0\n")},
+		ChartDir + "/values.yaml":        {Data: []byte("replicas: 1\nimage: my-controller:latest\n")},
+		ChartDir + "/templates/dep.yaml": {Data: []byte(testDeployment)},
+	}
+
+	overlayDir := filepath.Join(t.TempDir(), "my-controller")
+	overlay := newChartOverlayFS(base, overlayDir)
+
+	chartAddon, err := newChartAgentAddon(overlay, agent.AgentAddonOptions{AddonName: "my-controller"}, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	overlayAddon, err := newChartOverlayAgentAddon(chartAddon, overlay)
+	if err != nil {
+		t.Fatalf("expected no error without the overlay directory, got: %v", err)
+	}
+
+	if err := os.Mkdir(overlayDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if overlayAddon.refresh() {
+		t.Fatal("expected no change for an empty overlay directory")
+	}
+
+	writeTestAddon(t, overlayDir, map[string]string{"values.yaml": "replicas: 3\nimage: my-controller:hotfix\n"})
+
+	if !overlayAddon.refresh() {
+		t.Fatal("expected a change once the overlay directory overrides values.yaml")
+	}
+
+	if err := os.RemoveAll(overlayDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if !overlayAddon.refresh() || len(overlayAddon.overriddenFiles()) != 0 {
+		t.Fatalf("expected no overridden files once the overlay directory is removed, got: %v",
+			overlayAddon.overriddenFiles())
+	}
+}
@@ -1,25 +1,14 @@
 package policyframework
 
 import (
-	"context"
 	"embed"
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
-	"time"
 
-	"github.com/openshift/library-go/pkg/controller/controllercmd"
 	corev1 "k8s.io/api/core/v1"
 	"open-cluster-management.io/addon-framework/pkg/addonfactory"
-	"open-cluster-management.io/addon-framework/pkg/addonmanager"
-	"open-cluster-management.io/addon-framework/pkg/agent"
-	"open-cluster-management.io/addon-framework/pkg/utils"
 	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
-	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
-	clusterv1client "open-cluster-management.io/api/client/cluster/clientset/versioned"
-	clusterv1informers "open-cluster-management.io/api/client/cluster/informers/externalversions"
-	clusterlistersv1 "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 
@@ -74,7 +63,7 @@ func getSkeletonValues() policyFrameworkUserValues {
 	}
 }
 
-func getValuesFromAnnotations(clusterClient clusterlistersv1.ManagedClusterLister) func(*clusterv1.ManagedCluster,
+func getValuesFromAnnotations(clients *policyaddon.HubClients) func(*clusterv1.ManagedCluster,
 	*addonapiv1beta1.ManagedClusterAddOn,
 ) (addonfactory.Values, error) {
 	return func(
@@ -82,7 +71,7 @@ func getValuesFromAnnotations(clusterClient clusterlistersv1.ManagedClusterListe
 	) (addonfactory.Values, error) {
 		userValues := getSkeletonValues()
 
-		err := userValues.SetCommonValues(cluster, addon, clusterClient)
+		err := userValues.SetCommonValues(cluster, addon, clients)
 		if err != nil {
 			return nil, err
 		}
@@ -161,77 +150,22 @@ func getValuesFromCustomizedVariableValues(config addonapiv1beta1.AddOnDeploymen
 	return addonfactory.JsonStructToValues(userValues)
 }
 
-func GetAgentAddon(ctx context.Context, controllerContext *controllercmd.ControllerContext) (agent.AgentAddon, error) {
-	registrationOption := policyaddon.NewRegistrationOption(ctx,
-		controllerContext,
-		addonName,
-		agentPermissionFiles,
-		FS,
-		false)
-
-	addonClient, err := addonv1alpha1client.NewForConfig(controllerContext.KubeConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve addon client: %w", err)
-	}
-
-	clusterClient, err := clusterv1client.NewForConfig(controllerContext.KubeConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize a managed cluster client: %w", err)
-	}
-
-	clusterInformer := clusterv1informers.NewSharedInformerFactory(clusterClient, 10*time.Minute).
-		Cluster().V1().ManagedClusters()
-	go clusterInformer.Informer().Run(ctx.Done())
-
-	return addonfactory.NewAgentAddonFactory(addonName, FS, "manifests/managedclusterchart").
-		WithConfigGVRs(utils.AddOnDeploymentConfigGVR).
-		WithGetValuesFuncs(
-			getValuesFromAnnotations(clusterInformer.Lister()),
-			addonfactory.GetValuesFromAddonAnnotation,
-			addonfactory.GetAddOnDeploymentConfigValues(
-				utils.NewAddOnDeploymentConfigGetter(addonClient),
-				addonfactory.ToAddOnNodePlacementValues,
-				addonfactory.ToAddOnResourceRequirementsValues,
-				getValuesFromCustomizedVariableValues,
-			),
-			policyaddon.MandateValues,
-			mandateImageFromEnv,
-		).
-		WithManagedClusterClient(clusterClient).
-		WithAgentRegistrationOption(registrationOption).
-		WithAgentInstallNamespace(
-			policyaddon.
-				CommonAgentInstallNamespaceFromDeploymentConfigFunc(utils.NewAddOnDeploymentConfigGetter(addonClient)),
-		).
-		WithScheme(policyaddon.Scheme).
-		WithAgentHostedModeEnabledOption().
-		BuildHelmAgentAddon()
-}
-
-func GetAndAddAgent(
-	ctx context.Context, mgr addonmanager.AddonManager, controllerContext *controllercmd.ControllerContext,
-) error {
-	return policyaddon.GetAndAddAgent(ctx, mgr, addonName, controllerContext, GetAgentAddon)
-}
-
-// mandateImageFromEnv ensures that if the environment variable for the image is
-// set to a non-empty value, that value is used in the chart.
-func mandateImageFromEnv(
-	_ *clusterv1.ManagedCluster,
-	_ *addonapiv1beta1.ManagedClusterAddOn,
-) (addonfactory.Values, error) {
-	values := addonfactory.Values{}
-
-	img := os.Getenv("GOVERNANCE_POLICY_FRAMEWORK_ADDON_IMAGE")
-	if img == "" {
-		return values, nil
-	}
-
-	values["global"] = map[string]any{
-		"imageOverrides": map[string]any{
-			"governance_policy_framework_addon": img,
-		},
-	}
-
-	return values, nil
+// Descriptor declares the governance-policy-framework addon.
+var Descriptor = &policyaddon.AgentDescriptor{
+	Name:               addonName,
+	FS:                 FS,
+	HubPermissionFiles: agentPermissionFiles,
+	GetValuesFuncs: func(clients *policyaddon.HubClients) []addonfactory.GetValuesFunc {
+		return []addonfactory.GetValuesFunc{getValuesFromAnnotations(clients)}
+	},
+	CustomizedVariableValues: getValuesFromCustomizedVariableValues,
+	ImageEnvVar:              "GOVERNANCE_POLICY_FRAMEWORK_ADDON_IMAGE",
+	ImageKey:                 "governance_policy_framework_addon",
+	TLSProfile:               true,
+	GoRuntimeEnv:             true,
+	ExtraArgs: []string{
+		"leader-elect-lease-duration",
+		"leader-elect-renew-deadline",
+		"leader-elect-retry-period",
+	},
 }
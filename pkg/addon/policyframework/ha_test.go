@@ -0,0 +1,102 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package policyframework
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	policyaddon "open-cluster-management.io/governance-policy-addon-controller/pkg/addon"
+)
+
+func TestHighAvailabilityRender(t *testing.T) {
+	for _, replicas := range []int{1, 2} {
+		getValues := func(
+			_ *clusterv1.ManagedCluster, _ *addonapiv1beta1.ManagedClusterAddOn,
+		) (addonfactory.Values, error) {
+			values, err := getValuesFromCustomizedVariableValues(addonapiv1beta1.AddOnDeploymentConfig{})
+			if err != nil {
+				return nil, err
+			}
+
+			values["replicas"] = replicas
+
+			return values, nil
+		}
+
+		agentAddon, err := addonfactory.NewAgentAddonFactory(addonName, FS, "manifests/managedclusterchart").
+			WithGetValuesFuncs(getValues).
+			WithScheme(policyaddon.Scheme).
+			BuildHelmAgentAddon()
+		if err != nil {
+			t.Fatalf("expected the addon to build, got: %v", err)
+		}
+
+		cluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+		cluster.Status.Version.Kubernetes = "v1.30.0"
+		addon := &addonapiv1beta1.ManagedClusterAddOn{
+			ObjectMeta: metav1.ObjectMeta{Name: addonName, Namespace: "cluster1"},
+		}
+
+		objects, err := agentAddon.Manifests(context.TODO(), cluster, addon)
+		if err != nil {
+			t.Fatalf("expected the chart to render, got: %v", err)
+		}
+
+		var deployment *appsv1.Deployment
+
+		var pdb *policyv1.PodDisruptionBudget
+
+		for _, obj := range objects {
+			switch typed := obj.(type) {
+			case *appsv1.Deployment:
+				deployment = typed
+			case *policyv1.PodDisruptionBudget:
+				pdb = typed
+			}
+		}
+
+		if deployment == nil {
+			t.Fatal("expected the Deployment to be rendered")
+		}
+
+		affinity := deployment.Spec.Template.Spec.Affinity
+
+		if replicas == 1 {
+			if deployment.Spec.Strategy.Type == appsv1.RollingUpdateDeploymentStrategyType || pdb != nil {
+				t.Fatalf("expected no high availability with one replica, got the strategy %s and a PDB: %v",
+					deployment.Spec.Strategy.Type, pdb != nil)
+			}
+
+			if affinity != nil && affinity.PodAntiAffinity != nil {
+				t.Fatalf("expected no pod anti-affinity, got: %v", affinity.PodAntiAffinity)
+			}
+
+			continue
+		}
+
+		rollingUpdate := deployment.Spec.Strategy.RollingUpdate
+		if rollingUpdate == nil || rollingUpdate.MaxUnavailable.IntValue() != 0 {
+			t.Fatalf("expected a RollingUpdate strategy without unavailable replicas, got: %v",
+				deployment.Spec.Strategy)
+		}
+
+		if pdb == nil || pdb.Spec.MinAvailable.IntValue() != 1 ||
+			!maps.Equal(pdb.Spec.Selector.MatchLabels, deployment.Spec.Selector.MatchLabels) {
+			t.Fatalf("expected a PodDisruptionBudget keeping a replica of the Deployment, got: %v", pdb)
+		}
+
+		if affinity == nil || affinity.PodAntiAffinity == nil ||
+			len(affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution) != 1 {
+			t.Fatalf("expected the replicas to be spread across nodes, got: %v", affinity)
+		}
+	}
+}
@@ -58,3 +58,97 @@ Create role name used in role and binding for watching the ocm-tls-profile Confi
 {{- define "controller.tlsconfigmaprolename" -}}
     {{ template "controller.fullname" . }}-tls-configmap
 {{- end -}}
+
+{{/*
+Create the common labels, set per cluster, to add to the labels of every object
+*/}}
+{{- define "controller.commonLabels" -}}
+    {{- with .Values.global.commonLabels }}
+        {{- toYaml . }}
+    {{- end }}
+{{- end -}}
+
+{{/*
+Create the common annotations, set per cluster, to add to the annotations of every object
+*/}}
+{{- define "controller.commonAnnotations" -}}
+    {{- with .Values.global.commonAnnotations }}
+        {{- toYaml . }}
+    {{- end }}
+{{- end -}}
+
+{{/*
+Create the labels of the ServiceMonitor and PrometheusRule, where the labels set per cluster
+for the Prometheus selectors take precedence over the chart labels
+*/}}
+{{- define "controller.prometheusLabels" -}}
+    {{- $labels := dict
+        "app" (include "controller.fullname" .)
+        "chart" (include "controller.chart" .)
+        "release" .Release.Name
+        "heritage" .Release.Service
+        "addon.open-cluster-management.io/hosted-manifest-location" "hosting" }}
+    {{- $labels = merge (deepCopy (.Values.global.commonLabels | default dict)) $labels }}
+    {{- toYaml (merge (deepCopy (.Values.prometheus.serviceMonitor.labels | default dict)) $labels) }}
+{{- end -}}
+
+{{/*
+Create the source of the metrics serving certificate: "secret" for a user-supplied secret,
+"cert-manager" for a certificate issued by cert-manager, "service-ca" on OpenShift, or empty
+when the metrics are served over HTTP
+*/}}
+{{- define "controller.metricsTLSMode" -}}
+    {{- if .Values.prometheus.enabled }}
+        {{- if .Values.prometheus.tls.secretName }}
+            {{- "secret" }}
+        {{- else if .Values.prometheus.tls.issuer.name }}
+            {{- "cert-manager" }}
+        {{- else if eq .Values.hostingKubernetesDistribution "OpenShift" }}
+            {{- "service-ca" }}
+        {{- end }}
+    {{- end }}
+{{- end -}}
+
+{{/*
+Create the name of the secret with the metrics serving certificate
+*/}}
+{{- define "controller.metricsCertSecretName" -}}
+    {{- .Values.prometheus.tls.secretName | default (printf "%s-metrics" (include "controller.fullname" .)) }}
+{{- end -}}
+
+{{/*
+Create the namespace of the ServiceMonitor and PrometheusRule. When the ServiceMonitor verifies the
+metrics with the CA of a secret, it must be in the namespace of the secret, so the configured
+namespace is ignored.
+*/}}
+{{- define "controller.serviceMonitorNamespace" -}}
+    {{- $tlsMode := include "controller.metricsTLSMode" . }}
+    {{- if or (eq $tlsMode "secret") (eq $tlsMode "cert-manager") }}
+        {{- .Release.Namespace }}
+    {{- else }}
+        {{- .Values.prometheus.serviceMonitor.namespace | default .Release.Namespace }}
+    {{- end }}
+{{- end -}}
+
+{{/*
+Create the seccomp profile of the pods, when it is set by the distribution profile
+*/}}
+{{- define "controller.seccompProfile" -}}
+    {{- with .Values.distributionProfile.seccompProfileType }}
+seccompProfile:
+  type: {{ . }}
+    {{- end }}
+{{- end -}}
+
+{{/*
+Create the affinity of the pods, which spreads the replicas across nodes in the high-availability
+mode unless the affinity value already has a pod anti-affinity
+*/}}
+{{- define "controller.affinity" -}}
+    {{- $affinity := deepCopy (.Values.affinity | default dict) }}
+    {{- if and (gt (.Values.replicas | int) 1) (not (hasKey $affinity "podAntiAffinity")) }}
+        {{- $term := dict "topologyKey" "kubernetes.io/hostname" "labelSelector" (dict "matchLabels" (dict "app" (include "controller.fullname" .) "release" .Release.Name)) }}
+        {{- $_ := set $affinity "podAntiAffinity" (dict "preferredDuringSchedulingIgnoredDuringExecution" (list (dict "weight" 100 "podAffinityTerm" $term))) }}
+    {{- end }}
+    {{- toYaml $affinity }}
+{{- end -}}
@@ -0,0 +1,108 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package policyframework
+
+import (
+	"context"
+	"testing"
+
+	prometheusv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	policyaddon "open-cluster-management.io/governance-policy-addon-controller/pkg/addon"
+)
+
+func TestServiceMonitorNamespace(t *testing.T) {
+	tests := map[string]struct {
+		tlsVariable     *addonapiv1beta1.CustomizedVariable
+		expectedAgentNS bool
+	}{
+		"without TLS": {},
+		"with a metrics certificate secret": {
+			tlsVariable:     &addonapiv1beta1.CustomizedVariable{Name: "metricsCertSecret", Value: "metrics-cert"},
+			expectedAgentNS: true,
+		},
+		"with a cert-manager issuer": {
+			tlsVariable:     &addonapiv1beta1.CustomizedVariable{Name: "metricsCertIssuer", Value: "ca-issuer"},
+			expectedAgentNS: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := addonapiv1beta1.AddOnDeploymentConfig{}
+			config.Spec.CustomizedVariables = []addonapiv1beta1.CustomizedVariable{
+				{Name: "prometheusEnabled", Value: "true"},
+				{Name: "prometheusRuleEnabled", Value: "true"},
+				{Name: "serviceMonitorNamespace", Value: "monitoring"},
+			}
+
+			if test.tlsVariable != nil {
+				config.Spec.CustomizedVariables = append(config.Spec.CustomizedVariables, *test.tlsVariable)
+			}
+
+			getValues := func(
+				_ *clusterv1.ManagedCluster, _ *addonapiv1beta1.ManagedClusterAddOn,
+			) (addonfactory.Values, error) {
+				return getValuesFromCustomizedVariableValues(config)
+			}
+
+			agentAddon, err := addonfactory.NewAgentAddonFactory(addonName, FS, "manifests/managedclusterchart").
+				WithGetValuesFuncs(getValues).
+				WithScheme(policyaddon.Scheme).
+				BuildHelmAgentAddon()
+			if err != nil {
+				t.Fatalf("expected the addon to build, got: %v", err)
+			}
+
+			cluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+			cluster.Status.Version.Kubernetes = "v1.30.0"
+			addon := &addonapiv1beta1.ManagedClusterAddOn{
+				ObjectMeta: metav1.ObjectMeta{Name: addonName, Namespace: "cluster1"},
+			}
+
+			objects, err := agentAddon.Manifests(context.TODO(), cluster, addon)
+			if err != nil {
+				t.Fatalf("expected the chart to render, got: %v", err)
+			}
+
+			var deployment *appsv1.Deployment
+
+			var serviceMonitor *prometheusv1.ServiceMonitor
+
+			var prometheusRule *prometheusv1.PrometheusRule
+
+			for _, obj := range objects {
+				switch typed := obj.(type) {
+				case *appsv1.Deployment:
+					deployment = typed
+				case *prometheusv1.ServiceMonitor:
+					serviceMonitor = typed
+				case *prometheusv1.PrometheusRule:
+					prometheusRule = typed
+				}
+			}
+
+			if deployment == nil {
+				t.Fatal("expected the Deployment to be rendered")
+			}
+
+			expected := "monitoring"
+			if test.expectedAgentNS {
+				expected = deployment.Namespace
+			}
+
+			if serviceMonitor == nil || serviceMonitor.Namespace != expected {
+				t.Fatalf("expected the ServiceMonitor in the %s namespace, got: %v", expected, serviceMonitor)
+			}
+
+			if prometheusRule == nil || prometheusRule.Namespace != expected {
+				t.Fatalf("expected the PrometheusRule in the %s namespace, got: %v", expected, prometheusRule)
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package addon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/tools/cache"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// PostRenderPatchesAnnotation on the ClusterManagementAddOn references the ConfigMap, as
+	// <namespace>/<name>, containing the patches applied to the rendered manifests of the addon.
+	PostRenderPatchesAnnotation = "policy.open-cluster-management.io/post-render-patches"
+	// PostRenderPatchesLabel must be set to "true" on the ConfigMaps containing patches, since only
+	// these are cached by the controller.
+	PostRenderPatchesLabel = "policy.open-cluster-management.io/post-render-patches"
+	// PostRenderPatchesVariable is the AddOnDeploymentConfig customized variable naming a ConfigMap
+	// in the namespace of the AddOnDeploymentConfig containing the patches. It takes precedence
+	// over the ClusterManagementAddOn annotation.
+	PostRenderPatchesVariable = "postRenderPatches"
+
+	StrategicMergePatchType = "strategic"
+	MergePatchType          = "merge"
+	JSONPatchType           = "json"
+)
+
+// PostRenderFunc transforms the rendered manifests of an addon for a cluster.
+type PostRenderFunc func(
+	ctx context.Context,
+	cluster *clusterv1.ManagedCluster,
+	addon *addonapiv1beta1.ManagedClusterAddOn,
+	objects []runtime.Object,
+) ([]runtime.Object, error)
+
+// PostRenderPatch is a patch applied to the rendered objects matching its target. Each
+// key of the patches ConfigMap contains one, and they are applied in the order of the keys.
+type PostRenderPatch struct {
+	// Target selects the objects to patch. Empty fields match all objects.
+	Target PostRenderPatchTarget `json:"target,omitempty"`
+	// Type is one of "strategic" (the default), "merge" or "json".
+	Type string `json:"type,omitempty"`
+	// Patch is the patch in YAML or JSON.
+	Patch string `json:"patch"`
+}
+
+// PostRenderPatchTarget selects the objects a PostRenderPatch applies to.
+type PostRenderPatchTarget struct {
+	Group     string `json:"group,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// GetPostRenderPatchesFunc returns a PostRenderFunc applying the patches from the ConfigMap
+// referenced by the AddOnDeploymentConfig of the addon, or otherwise by the
+// ClusterManagementAddOn.
+func GetPostRenderPatchesFunc(clients *HubClients) PostRenderFunc {
+	return func(
+		_ context.Context,
+		_ *clusterv1.ManagedCluster,
+		addon *addonapiv1beta1.ManagedClusterAddOn,
+		objects []runtime.Object,
+	) ([]runtime.Object, error) {
+		namespace, name, err := getPostRenderPatchesRef(clients, addon)
+		if err != nil {
+			return nil, err
+		}
+
+		// Track the ConfigMap even when it doesn't exist yet, so that its creation renders the addon again
+		clients.postRenderPatchesRefs.set(types.NamespacedName{Namespace: addon.Namespace, Name: addon.Name},
+			namespace, name)
+
+		if name == "" {
+			return objects, nil
+		}
+
+		configMap, err := clients.ConfigMapLister.ConfigMaps(namespace).Get(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the post-render patches ConfigMap %s/%s with the %s=true label: %w",
+				namespace, name, PostRenderPatchesLabel, err)
+		}
+
+		keys := make([]string, 0, len(configMap.Data))
+		for key := range configMap.Data {
+			keys = append(keys, key)
+		}
+
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			patch := PostRenderPatch{}
+
+			if err := yaml.UnmarshalStrict([]byte(configMap.Data[key]), &patch); err != nil {
+				return nil, fmt.Errorf("failed to parse the post-render patch %s in %s/%s: %w",
+					key, namespace, name, err)
+			}
+
+			objects, err = applyPostRenderPatch(objects, patch)
+			if err != nil {
+				return nil, fmt.Errorf("failed to apply the post-render patch %s in %s/%s: %w",
+					key, namespace, name, err)
+			}
+		}
+
+		return objects, nil
+	}
+}
+
+// getPostRenderPatchesRef returns the namespace and name of the ConfigMap with the patches
+// for the addon, or an empty name when there are none.
+func getPostRenderPatchesRef(
+	clients *HubClients, addon *addonapiv1beta1.ManagedClusterAddOn,
+) (string, string, error) {
+	config, err := utils.GetDesiredAddOnDeploymentConfig(addon, clients.ADCGetter)
+	if err != nil {
+		return "", "", err
+	}
+
+	if config != nil {
+		for _, variable := range config.Spec.CustomizedVariables {
+			if variable.Name == PostRenderPatchesVariable && variable.Value != "" {
+				return config.Namespace, variable.Value, nil
+			}
+		}
+	}
+
+	cma, err := clients.ClusterAddonLister.Get(addon.Name)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get the ClusterManagementAddOn %s: %w", addon.Name, err)
+	}
+
+	ref, ok := cma.GetAnnotations()[PostRenderPatchesAnnotation]
+	if !ok || ref == "" {
+		return "", "", nil
+	}
+
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || namespace == "" || name == "" {
+		return "", "", fmt.Errorf("invalid '%s' annotation value '%s', expected <namespace>/<name>",
+			PostRenderPatchesAnnotation, ref)
+	}
+
+	return namespace, name, nil
+}
+
+// postRenderPatchesRefs tracks the patches ConfigMap of each rendered ManagedClusterAddOn, so that
+// the addons are rendered again when their ConfigMap changes.
+type postRenderPatchesRefs struct {
+	lock        sync.Mutex
+	byAddon     map[types.NamespacedName]types.NamespacedName
+	byConfigMap map[types.NamespacedName]sets.Set[types.NamespacedName]
+}
+
+func newPostRenderPatchesRefs() *postRenderPatchesRefs {
+	return &postRenderPatchesRefs{
+		byAddon:     map[types.NamespacedName]types.NamespacedName{},
+		byConfigMap: map[types.NamespacedName]sets.Set[types.NamespacedName]{},
+	}
+}
+
+// set records the patches ConfigMap of the ManagedClusterAddOn, or that it has none when the
+// name is empty.
+func (r *postRenderPatchesRefs) set(addon types.NamespacedName, namespace, name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	configMap := types.NamespacedName{Namespace: namespace, Name: name}
+
+	if previous, ok := r.byAddon[addon]; ok {
+		if previous == configMap {
+			return
+		}
+
+		r.byConfigMap[previous].Delete(addon)
+
+		if r.byConfigMap[previous].Len() == 0 {
+			delete(r.byConfigMap, previous)
+		}
+
+		delete(r.byAddon, addon)
+	}
+
+	if name == "" {
+		return
+	}
+
+	r.byAddon[addon] = configMap
+
+	if r.byConfigMap[configMap] == nil {
+		r.byConfigMap[configMap] = sets.New[types.NamespacedName]()
+	}
+
+	r.byConfigMap[configMap].Insert(addon)
+}
+
+// addons returns the ManagedClusterAddOns patched by the ConfigMap.
+func (r *postRenderPatchesRefs) addons(configMap types.NamespacedName) []types.NamespacedName {
+	if r == nil {
+		return nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.byConfigMap[configMap].UnsortedList()
+}
+
+// watchPostRenderPatches renders the addons again when their patches ConfigMap changes, and when
+// the post-render patches annotation of their ClusterManagementAddOn changes.
+func watchPostRenderPatches(mgr addonmanager.AddonManager, clients *HubClients, addonNames []string) error {
+	_, err := clients.configMapInformer.AddEventHandler(
+		postRenderPatchesConfigMapHandler(mgr, clients.postRenderPatchesRefs),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to watch the post-render patches ConfigMaps: %w", err)
+	}
+
+	_, err = clients.AddonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(oldObj, newObj any) {
+				oldCMA, oldOK := oldObj.(metav1.Object)
+				newCMA, newOK := newObj.(metav1.Object)
+
+				if !oldOK || !newOK || !slices.Contains(addonNames, newCMA.GetName()) ||
+					oldCMA.GetAnnotations()[PostRenderPatchesAnnotation] ==
+						newCMA.GetAnnotations()[PostRenderPatchesAnnotation] {
+					return
+				}
+
+				triggerAddons(mgr, clients.AddonLister, newCMA.GetName())
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to watch the ClusterManagementAddOns: %w", err)
+	}
+
+	return nil
+}
+
+// postRenderPatchesConfigMapHandler triggers the ManagedClusterAddOns patched by a ConfigMap when it
+// changes.
+func postRenderPatchesConfigMapHandler(
+	mgr addonmanager.AddonManager, refs *postRenderPatchesRefs,
+) cache.ResourceEventHandler {
+	trigger := func(obj any) {
+		key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+		if err != nil {
+			return
+		}
+
+		namespace, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			return
+		}
+
+		for _, addon := range refs.addons(types.NamespacedName{Namespace: namespace, Name: name}) {
+			mgr.Trigger(addon.Namespace, addon.Name)
+		}
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: trigger,
+		UpdateFunc: func(oldObj, newObj any) {
+			oldConfigMap, oldOK := oldObj.(metav1.Object)
+			newConfigMap, newOK := newObj.(metav1.Object)
+
+			// Skip the periodic resyncs
+			if oldOK && newOK && oldConfigMap.GetResourceVersion() == newConfigMap.GetResourceVersion() {
+				return
+			}
+
+			trigger(newObj)
+		},
+		DeleteFunc: trigger,
+	}
+}
+
+// applyPostRenderPatch applies the patch to each object matching its target.
+func applyPostRenderPatch(objects []runtime.Object, patch PostRenderPatch) ([]runtime.Object, error) {
+	patchJSON, err := yaml.YAMLToJSON([]byte(patch.Patch))
+	if err != nil {
+		return nil, fmt.Errorf("invalid patch: %w", err)
+	}
+
+	patched := make([]runtime.Object, 0, len(objects))
+
+	for _, obj := range objects {
+		matches, err := patch.Target.matches(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		if !matches {
+			patched = append(patched, obj)
+
+			continue
+		}
+
+		original, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		var result []byte
+
+		switch patch.Type {
+		case "", StrategicMergePatchType:
+			// The objects without a Go type, such as the cert-manager Certificate, have no patch
+			// strategy, so a JSON merge patch is applied instead
+			if _, ok := obj.(runtime.Unstructured); ok {
+				result, err = jsonpatch.MergePatch(original, patchJSON)
+			} else {
+				result, err = strategicpatch.StrategicMergePatch(original, patchJSON, obj)
+			}
+		case MergePatchType:
+			result, err = jsonpatch.MergePatch(original, patchJSON)
+		case JSONPatchType:
+			var decoded jsonpatch.Patch
+
+			decoded, err = jsonpatch.DecodePatch(patchJSON)
+			if err == nil {
+				result, err = decoded.Apply(original)
+			}
+		default:
+			return nil, fmt.Errorf("unknown patch type '%s'", patch.Type)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Decode into a new object of the same type, so fields removed by the patch are cleared
+		newObj, ok := reflect.New(reflect.TypeOf(obj).Elem()).Interface().(runtime.Object)
+		if !ok {
+			return nil, fmt.Errorf("unable to create a new object of type %T", obj)
+		}
+
+		if err := json.Unmarshal(result, newObj); err != nil {
+			return nil, err
+		}
+
+		patched = append(patched, newObj)
+	}
+
+	return patched, nil
+}
+
+// matches returns whether the object is selected by the target.
+func (t PostRenderPatchTarget) matches(obj runtime.Object) (bool, error) {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() {
+		gvks, _, err := Scheme.ObjectKinds(obj)
+		if err != nil {
+			return false, err
+		}
+
+		gvk = gvks[0]
+	}
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false, err
+	}
+
+	return matchesField(t.Group, gvk.Group) &&
+		matchesField(t.Version, gvk.Version) &&
+		matchesField(t.Kind, gvk.Kind) &&
+		matchesField(t.Name, accessor.GetName()) &&
+		matchesField(t.Namespace, accessor.GetNamespace()), nil
+}
+
+func matchesField(selector, value string) bool {
+	return selector == "" || selector == value
+}
@@ -0,0 +1,228 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	addonlistersv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+func TestApplyPostRenderPatch(t *testing.T) {
+	newObjects := func() []runtime.Object {
+		return []runtime.Object{
+			&appsv1.Deployment{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+				ObjectMeta: metav1.ObjectMeta{Name: "controller", Namespace: "agent"},
+				Spec: appsv1.DeploymentSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: "manager", Image: "manager:latest"}},
+						},
+					},
+				},
+			},
+			&corev1.ServiceAccount{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+				ObjectMeta: metav1.ObjectMeta{Name: "controller", Namespace: "agent"},
+			},
+		}
+	}
+
+	t.Run("strategic merge adds a sidecar", func(t *testing.T) {
+		objects, err := applyPostRenderPatch(newObjects(), PostRenderPatch{
+			Target: PostRenderPatchTarget{Kind: "Deployment"},
+			Patch:  "spec:\n  template:\n    spec:\n      containers:\n      - name: sidecar\n        image: sidecar:1\n",
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		containers := objects[0].(*appsv1.Deployment).Spec.Template.Spec.Containers
+		names := []string{containers[0].Name, containers[len(containers)-1].Name}
+		if len(containers) != 2 || !slices.Contains(names, "manager") || !slices.Contains(names, "sidecar") {
+			t.Fatalf("expected the sidecar to be merged with the manager container, got: %v", containers)
+		}
+
+		if len(objects[1].(*corev1.ServiceAccount).Labels) != 0 {
+			t.Fatal("expected the ServiceAccount to not be patched")
+		}
+	})
+
+	t.Run("json patch adds a label to every object", func(t *testing.T) {
+		objects, err := applyPostRenderPatch(newObjects(), PostRenderPatch{
+			Type:  JSONPatchType,
+			Patch: `[{"op": "add", "path": "/metadata/labels", "value": {"org": "example"}}]`,
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		for _, obj := range objects {
+			if labels := obj.(metav1.Object).GetLabels(); labels["org"] != "example" {
+				t.Fatalf("expected the org label on %T, got: %v", obj, labels)
+			}
+		}
+	})
+
+	t.Run("strategic merge without a target labels an unstructured object", func(t *testing.T) {
+		certificate := &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "cert-manager.io/v1",
+			"kind":       "Certificate",
+			"metadata":   map[string]any{"name": "controller-metrics", "namespace": "agent"},
+			"spec":       map[string]any{"secretName": "controller-metrics", "dnsNames": []any{"metrics"}},
+		}}
+
+		objects, err := applyPostRenderPatch(append(newObjects(), certificate), PostRenderPatch{
+			Patch: "metadata:\n  labels:\n    org: example\n",
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		for _, obj := range objects {
+			if labels := obj.(metav1.Object).GetLabels(); labels["org"] != "example" {
+				t.Fatalf("expected the org label on %T, got: %v", obj, labels)
+			}
+		}
+
+		patched, ok := objects[2].(*unstructured.Unstructured)
+		if !ok {
+			t.Fatalf("expected an unstructured Certificate, got: %T", objects[2])
+		}
+
+		if secretName, _, _ := unstructured.NestedString(patched.Object, "spec", "secretName"); secretName == "" {
+			t.Fatalf("expected the Certificate spec to be kept, got: %v", patched.Object)
+		}
+	})
+
+	t.Run("unknown patch type", func(t *testing.T) {
+		_, err := applyPostRenderPatch(newObjects(), PostRenderPatch{Type: "other", Patch: "{}"})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+// triggerRecorder records the addons triggered on the manager.
+type triggerRecorder struct {
+	addonmanager.AddonManager
+	triggered []string
+}
+
+func (r *triggerRecorder) Trigger(clusterName, addonName string) {
+	r.triggered = append(r.triggered, clusterName+"/"+addonName)
+}
+
+func TestGetPostRenderPatchesFunc(t *testing.T) {
+	configMapIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	cmaIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+
+	err := cmaIndexer.Add(&addonapiv1alpha1.ClusterManagementAddOn{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "my-controller",
+			Annotations: map[string]string{PostRenderPatchesAnnotation: "patches-ns/cma-patches"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected the ClusterManagementAddOn to be cached, got: %v", err)
+	}
+
+	err = configMapIndexer.Add(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cma-patches",
+			Namespace: "patches-ns",
+			Labels:    map[string]string{PostRenderPatchesLabel: "true"},
+		},
+		Data: map[string]string{
+			"replicas": "target:\n  kind: Deployment\npatch: |\n  spec:\n    replicas: 2\n",
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected the ConfigMap to be cached, got: %v", err)
+	}
+
+	addon, getter := newTestAddon(addonapiv1beta1.AddOnDeploymentConfigSpec{}, nil)
+
+	clients := &HubClients{
+		ADCGetter:             getter,
+		ConfigMapLister:       corev1listers.NewConfigMapLister(configMapIndexer),
+		ClusterAddonLister:    addonlistersv1alpha1.NewClusterManagementAddOnLister(cmaIndexer),
+		postRenderPatchesRefs: newPostRenderPatchesRefs(),
+	}
+
+	deployment := &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "controller", Namespace: "agent"},
+	}
+
+	objects, err := GetPostRenderPatchesFunc(clients)(
+		context.TODO(), &clusterv1.ManagedCluster{}, addon, []runtime.Object{deployment},
+	)
+	if err != nil {
+		t.Fatalf("expected the patches to apply, got: %v", err)
+	}
+
+	patched, ok := objects[0].(*appsv1.Deployment)
+	if !ok || patched.Spec.Replicas == nil || *patched.Spec.Replicas != 2 {
+		t.Fatalf("expected the Deployment to be patched from the ClusterManagementAddOn ConfigMap, got: %v",
+			objects[0])
+	}
+
+	recorder := &triggerRecorder{}
+	handler := postRenderPatchesConfigMapHandler(recorder, clients.postRenderPatchesRefs)
+
+	handler.OnAdd(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "patches-ns"}}, false)
+
+	if len(recorder.triggered) != 0 {
+		t.Fatalf("expected no trigger for an unrelated ConfigMap, got: %v", recorder.triggered)
+	}
+
+	handler.OnUpdate(
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cma-patches", Namespace: "patches-ns", ResourceVersion: "1"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cma-patches", Namespace: "patches-ns", ResourceVersion: "2"}},
+	)
+
+	if !slices.Equal(recorder.triggered, []string{"cluster1/my-controller"}) {
+		t.Fatalf("expected the addon patched by the ConfigMap to be triggered, got: %v", recorder.triggered)
+	}
+
+	// The AddOnDeploymentConfig reference takes precedence, and the addon stops tracking the
+	// ClusterManagementAddOn ConfigMap
+	addon, getter = newTestAddon(addonapiv1beta1.AddOnDeploymentConfigSpec{
+		CustomizedVariables: []addonapiv1beta1.CustomizedVariable{
+			{Name: PostRenderPatchesVariable, Value: "adc-patches"},
+		},
+	}, nil)
+	clients.ADCGetter = getter
+
+	_, err = GetPostRenderPatchesFunc(clients)(
+		context.TODO(), &clusterv1.ManagedCluster{}, addon, []runtime.Object{deployment},
+	)
+	if err == nil {
+		t.Fatal("expected an error for the missing AddOnDeploymentConfig ConfigMap")
+	}
+
+	recorder.triggered = nil
+
+	handler.OnDelete(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cma-patches", Namespace: "patches-ns"}})
+	handler.OnAdd(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "adc-patches", Namespace: "cluster1"}}, false)
+
+	if !slices.Equal(recorder.triggered, []string{"cluster1/my-controller"}) {
+		t.Fatalf("expected only the creation of the missing ConfigMap to trigger the addon, got: %v",
+			recorder.triggered)
+	}
+}
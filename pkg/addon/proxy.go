@@ -0,0 +1,123 @@
+package addon
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+)
+
+// getHubClusterInfo returns the cluster of the kubeconfig in the kube-public/cluster-info
+// ConfigMap, which the managed clusters use to connect to the hub, or nil when it isn't set.
+func getHubClusterInfo(ctx context.Context, kubeClient kubernetes.Interface) (*clientcmdapi.Cluster, error) {
+	clusterInfo, err := kubeClient.CoreV1().ConfigMaps("kube-public").Get(ctx, "cluster-info", metav1.GetOptions{})
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get the kube-public/cluster-info ConfigMap: %w", err)
+	}
+
+	kubeConfig, err := clientcmd.Load([]byte(clusterInfo.Data["kubeconfig"]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the kubeconfig in kube-public/cluster-info: %w", err)
+	}
+
+	for _, cluster := range kubeConfig.Clusters {
+		if cluster.Server != "" {
+			return cluster, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// GetHubAPIServer returns the URL of the hub API server that the managed clusters connect to.
+// It is read from the kubeconfig in the kube-public/cluster-info ConfigMap, and otherwise
+// falls back to the given URL.
+func GetHubAPIServer(ctx context.Context, kubeClient kubernetes.Interface, fallback string) (string, error) {
+	cluster, err := getHubClusterInfo(ctx, kubeClient)
+	if err != nil {
+		return "", err
+	}
+
+	if cluster == nil {
+		return fallback, nil
+	}
+
+	return cluster.Server, nil
+}
+
+// GetHubCA returns the PEM CA bundle of the hub API server that the managed clusters connect to.
+// It is read from the kubeconfig in the kube-public/cluster-info ConfigMap, and otherwise falls
+// back to the CA of the controller's kubeconfig.
+func GetHubCA(ctx context.Context, kubeClient kubernetes.Interface, kubeConfig *rest.Config) ([]byte, error) {
+	cluster, err := getHubClusterInfo(ctx, kubeClient)
+	if err != nil {
+		return nil, err
+	}
+
+	if cluster != nil && len(cluster.CertificateAuthorityData) > 0 {
+		return cluster.CertificateAuthorityData, nil
+	}
+
+	if len(kubeConfig.CAData) > 0 || kubeConfig.CAFile == "" {
+		return kubeConfig.CAData, nil
+	}
+
+	caData, err := os.ReadFile(kubeConfig.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CA file of the controller's kubeconfig: %w", err)
+	}
+
+	return caData, nil
+}
+
+// getProxyConfigValuesFunc returns a function converting the proxy configuration of the
+// AddOnDeploymentConfig to chart values, with the host of the hub API server added to the
+// hosts that aren't proxied, since the agents connect to the hub directly.
+func getProxyConfigValuesFunc(hubAPIServer string) addonfactory.AddOnDeploymentConfigToValuesFunc {
+	hubAPIHost := ""
+
+	if hubURL, err := url.Parse(hubAPIServer); err == nil {
+		hubAPIHost = hubURL.Hostname()
+	}
+
+	return func(config addonapiv1beta1.AddOnDeploymentConfig) (addonfactory.Values, error) {
+		values, err := addonfactory.ToAddOnProxyConfigValues(config)
+		if err != nil || values == nil || hubAPIHost == "" {
+			return values, err
+		}
+
+		noProxy := []string{}
+
+		for host := range strings.SplitSeq(config.Spec.ProxyConfig.NoProxy, ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				noProxy = append(noProxy, host)
+			}
+		}
+
+		if !slices.Contains(noProxy, hubAPIHost) {
+			noProxy = append(noProxy, hubAPIHost)
+		}
+
+		global, _ := values["global"].(map[string]any)
+		if proxyConfig, ok := global["proxyConfig"].(map[string]any); ok {
+			proxyConfig["NO_PROXY"] = strings.Join(noProxy, ",")
+		}
+
+		return values, nil
+	}
+}
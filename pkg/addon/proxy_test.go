@@ -0,0 +1,203 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+)
+
+// newClusterInfo returns the kube-public/cluster-info ConfigMap with the kubeconfig.
+func newClusterInfo(kubeConfig string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-info", Namespace: "kube-public"},
+		Data:       map[string]string{"kubeconfig": kubeConfig},
+	}
+}
+
+const (
+	hubKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: hub
+  cluster:
+    server: https://api.hub.example.com:6443
+    certificate-authority-data: aHViLWNh
+`
+	hubKubeConfigWithoutCA = `apiVersion: v1
+kind: Config
+clusters:
+- name: hub
+  cluster:
+    server: https://api.hub.example.com:6443
+`
+	hubKubeConfigWithoutServer = `apiVersion: v1
+kind: Config
+clusters:
+- name: hub
+  cluster:
+    certificate-authority-data: aHViLWNh
+`
+)
+
+func TestGetHubAPIServer(t *testing.T) {
+	tests := map[string]struct {
+		clusterInfo *corev1.ConfigMap
+		expected    string
+		expectedErr bool
+	}{
+		"no cluster-info": {
+			expected: "https://fallback.example.com:6443",
+		},
+		"cluster-info": {
+			clusterInfo: newClusterInfo(hubKubeConfig),
+			expected:    "https://api.hub.example.com:6443",
+		},
+		"cluster-info without a server": {
+			clusterInfo: newClusterInfo(hubKubeConfigWithoutServer),
+			expected:    "https://fallback.example.com:6443",
+		},
+		"invalid cluster-info": {
+			clusterInfo: newClusterInfo("clusters: {"),
+			expectedErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			kubeClient := kubefake.NewClientset()
+			if test.clusterInfo != nil {
+				kubeClient = kubefake.NewClientset(test.clusterInfo)
+			}
+
+			server, err := GetHubAPIServer(context.TODO(), kubeClient, "https://fallback.example.com:6443")
+			if (err != nil) != test.expectedErr {
+				t.Fatalf("expected an error: %v, got: %v", test.expectedErr, err)
+			}
+
+			if server != test.expected {
+				t.Fatalf("expected the hub API server %q, got: %q", test.expected, server)
+			}
+		})
+	}
+}
+
+func TestGetHubCA(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+
+	if err := os.WriteFile(caFile, []byte("file-ca"), 0o600); err != nil {
+		t.Fatalf("expected the CA file to be written, got: %v", err)
+	}
+
+	controllerCA := rest.TLSClientConfig{CAData: []byte("controller-ca")}
+
+	tests := map[string]struct {
+		clusterInfo *corev1.ConfigMap
+		tlsConfig   rest.TLSClientConfig
+		expected    string
+		expectedErr bool
+	}{
+		"no cluster-info": {
+			tlsConfig: controllerCA,
+			expected:  "controller-ca",
+		},
+		"cluster-info": {
+			clusterInfo: newClusterInfo(hubKubeConfig),
+			tlsConfig:   controllerCA,
+			expected:    "hub-ca",
+		},
+		"cluster-info without a CA": {
+			clusterInfo: newClusterInfo(hubKubeConfigWithoutCA),
+			tlsConfig:   controllerCA,
+			expected:    "controller-ca",
+		},
+		// The CA of a kubeconfig without a server isn't used
+		"cluster-info without a server": {
+			clusterInfo: newClusterInfo(hubKubeConfigWithoutServer),
+			tlsConfig:   controllerCA,
+			expected:    "controller-ca",
+		},
+		"invalid cluster-info": {
+			clusterInfo: newClusterInfo("clusters: {"),
+			tlsConfig:   controllerCA,
+			expectedErr: true,
+		},
+		"CA file": {
+			tlsConfig: rest.TLSClientConfig{CAFile: caFile},
+			expected:  "file-ca",
+		},
+		"missing CA file": {
+			tlsConfig:   rest.TLSClientConfig{CAFile: filepath.Join(t.TempDir(), "missing.crt")},
+			expectedErr: true,
+		},
+		"no CA": {},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			kubeClient := kubefake.NewClientset()
+			if test.clusterInfo != nil {
+				kubeClient = kubefake.NewClientset(test.clusterInfo)
+			}
+
+			caData, err := GetHubCA(context.TODO(), kubeClient, &rest.Config{TLSClientConfig: test.tlsConfig})
+			if (err != nil) != test.expectedErr {
+				t.Fatalf("expected an error: %v, got: %v", test.expectedErr, err)
+			}
+
+			if string(caData) != test.expected {
+				t.Fatalf("expected the hub CA %q, got: %q", test.expected, caData)
+			}
+		})
+	}
+}
+
+func TestGetProxyConfigValuesFunc(t *testing.T) {
+	toValues := getProxyConfigValuesFunc("https://api.hub.example.com:6443")
+
+	t.Run("no proxy", func(t *testing.T) {
+		values, err := toValues(addonapiv1beta1.AddOnDeploymentConfig{})
+		if err != nil || len(values) != 0 {
+			t.Fatalf("expected no values, got: %v, %v", values, err)
+		}
+	})
+
+	t.Run("proxy with a CA bundle", func(t *testing.T) {
+		config := addonapiv1beta1.AddOnDeploymentConfig{
+			Spec: addonapiv1beta1.AddOnDeploymentConfigSpec{
+				ProxyConfig: addonapiv1beta1.ProxyConfig{
+					HTTPSProxy: "https://proxy.example.com:3128",
+					NoProxy:    "10.0.0.0/8, .cluster.local",
+					CABundle:   []byte("my-ca"),
+				},
+			},
+		}
+
+		values, err := toValues(config)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		proxyConfig, err := chartutil.Values(values).Table("global.proxyConfig")
+		if err != nil {
+			t.Fatalf("expected proxy values, got: %v", values)
+		}
+
+		if proxyConfig["NO_PROXY"] != "10.0.0.0/8,.cluster.local,api.hub.example.com" {
+			t.Fatalf("expected the hub API host to be added to NO_PROXY, got: %v", proxyConfig["NO_PROXY"])
+		}
+
+		if proxyConfig["HTTPS_PROXY"] != "https://proxy.example.com:3128" || proxyConfig["PROXY_CA_BUNDLE"] != "my-ca" {
+			t.Fatalf("expected the proxy and CA bundle to be set, got: %v", proxyConfig)
+		}
+	})
+}
@@ -3,18 +3,14 @@ package standalonetemplating
 import (
 	"context"
 	"embed"
-	"fmt"
 
-	"github.com/openshift/library-go/pkg/controller/controllercmd"
 	"k8s.io/apimachinery/pkg/runtime"
 	"open-cluster-management.io/addon-framework/pkg/addonfactory"
 	"open-cluster-management.io/addon-framework/pkg/addonmanager"
 	"open-cluster-management.io/addon-framework/pkg/agent"
-	"open-cluster-management.io/addon-framework/pkg/utils"
 	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
-	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
-	clusterv1client "open-cluster-management.io/api/client/cluster/clientset/versioned"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
 
 	policyaddon "open-cluster-management.io/governance-policy-addon-controller/pkg/addon"
 )
@@ -31,6 +27,8 @@ const (
 //go:embed manifests/managedclusterchart/templates/_helpers.tpl
 var FS embed.FS
 
+var log = ctrl.Log.WithName("standalonetemplating")
+
 var agentPermissionFiles = []string{
 	"manifests/hubpermissions/role.yaml",
 	"manifests/hubpermissions/rolebinding.yaml",
@@ -48,41 +46,65 @@ func getValues(
 	return values, nil
 }
 
-func getAgentAddon(ctx context.Context, controllerContext *controllercmd.ControllerContext) (agent.AgentAddon, error) {
-	registrationOption := policyaddon.NewRegistrationOption(ctx,
-		controllerContext,
-		addonName,
-		agentPermissionFiles,
-		FS,
-		true)
-
-	addonClient, err := addonv1alpha1client.NewForConfig(controllerContext.KubeConfig)
+// getValuesFromCustomizedVariables sets the common labels and annotations from the
+// customized variables, and passes the others to the chart as is.
+func getValuesFromCustomizedVariables(config addonapiv1beta1.AddOnDeploymentConfig) (addonfactory.Values, error) {
+	values, err := addonfactory.ToAddOnCustomizedVariableValues(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve addon client: %w", err)
+		return nil, err
+	}
+
+	userValues := policyaddon.CommonValues{}
+
+	for _, variable := range config.Spec.CustomizedVariables {
+		var err error
+
+		switch variable.Name {
+		case "commonLabels":
+			err = userValues.SetCommonLabels(variable.Value)
+		case "commonAnnotations":
+			err = userValues.SetCommonAnnotations(variable.Value)
+		default:
+			continue
+		}
+
+		if err != nil {
+			log.Error(err, "error setting customized variable", "variable", variable.Name)
+		}
+
+		delete(values, variable.Name)
 	}
 
-	clusterClient, err := clusterv1client.NewForConfig(controllerContext.KubeConfig)
+	globalValues, err := addonfactory.JsonStructToValues(userValues)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize a managed cluster client: %w", err)
+		return nil, err
 	}
 
-	return addonfactory.NewAgentAddonFactory(addonName, FS, "manifests/managedclusterchart").
-		WithConfigGVRs(utils.AddOnDeploymentConfigGVR).
-		WithGetValuesFuncs(
-			addonfactory.GetAddOnDeploymentConfigValues(
-				utils.NewAddOnDeploymentConfigGetter(addonClient),
-				addonfactory.ToAddOnNodePlacementValues,
-				addonfactory.ToAddOnCustomizedVariableValues,
-			),
-			getValues).
-		WithManagedClusterClient(clusterClient).
-		WithAgentRegistrationOption(registrationOption).
-		WithAgentInstallNamespace(
-			policyaddon.
-				CommonAgentInstallNamespaceFromDeploymentConfigFunc(utils.NewAddOnDeploymentConfigGetter(addonClient)),
-		).
-		WithAgentHostedModeEnabledOption().
-		BuildHelmAgentAddon()
+	return addonfactory.MergeValues(values, globalValues), nil
+}
+
+// Descriptor declares the governance-standalone-hub-templating addon.
+var Descriptor = &policyaddon.AgentDescriptor{
+	Name:                     addonName,
+	FS:                       FS,
+	HubPermissionFiles:       agentPermissionFiles,
+	HubPermissionsForAddon:   true,
+	CustomizedVariableValues: getValuesFromCustomizedVariables,
+	// The hub group and the info published to the managed cluster can't be overridden
+	MandatedValuesFuncs: func(clients *policyaddon.HubClients) []addonfactory.GetValuesFunc {
+		return []addonfactory.GetValuesFunc{getValues, getHubInfoValues(clients)}
+	},
+	// The chart only deploys the info secret
+	NoAgent: true,
+	// Hub templating is an optional feature of the config-policy-controller
+	Optional:      true,
+	HubController: runPermissionsController,
+	WrapAgent: func(agentAddon agent.AgentAddon, mgr addonmanager.AddonManager) agent.AgentAddon {
+		return &StandaloneAgentAddon{
+			AgentAddon: agentAddon,
+			manager:    mgr,
+		}
+	},
 }
 
 type StandaloneAgentAddon struct {
@@ -100,24 +122,3 @@ func (sa *StandaloneAgentAddon) Manifests(
 
 	return sa.AgentAddon.Manifests(ctx, cluster, addon)
 }
-
-func GetAndAddAgent(
-	ctx context.Context, mgr addonmanager.AddonManager, controllerContext *controllercmd.ControllerContext,
-) error {
-	agentAddon, err := getAgentAddon(ctx, controllerContext)
-	if err != nil {
-		return fmt.Errorf("failed getting the %v agent addon: %w", addonName, err)
-	}
-
-	standaloneAgentAddon := &StandaloneAgentAddon{
-		AgentAddon: agentAddon,
-		manager:    mgr,
-	}
-
-	err = mgr.AddAgent(standaloneAgentAddon)
-	if err != nil {
-		return fmt.Errorf("failed adding the %v agent addon to the manager: %w", addonName, err)
-	}
-
-	return nil
-}
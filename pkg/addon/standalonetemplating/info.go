@@ -0,0 +1,396 @@
+package standalonetemplating
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"sigs.k8s.io/yaml"
+
+	policyaddon "open-cluster-management.io/governance-policy-addon-controller/pkg/addon"
+)
+
+// The registration states published in the info secret.
+const (
+	registrationPending               = "Pending"
+	registrationWaitingForCertificate = "WaitingForCertificate"
+	registrationRegistered            = "Registered"
+)
+
+// HubPermission is a rule of a role bound to the groups of the addon on a managed cluster, granted
+// in the namespace of a RoleBinding, or cluster-wide for a ClusterRoleBinding.
+type HubPermission struct {
+	Namespace         string `json:"namespace,omitempty"`
+	Binding           string `json:"binding"`
+	Role              string `json:"role"`
+	rbacv1.PolicyRule `json:",inline"`
+}
+
+// The indexes of the ClusterRoleBindings and RoleBindings by the groups of their subjects, and by
+// the role they bind.
+const (
+	bindingGroupIndex = "bindingGroup"
+	bindingRoleIndex  = "bindingRole"
+)
+
+// bindingGroups indexes a ClusterRoleBinding or RoleBinding by the groups of its subjects.
+func bindingGroups(obj any) ([]string, error) {
+	var subjects []rbacv1.Subject
+
+	switch binding := obj.(type) {
+	case *rbacv1.ClusterRoleBinding:
+		subjects = binding.Subjects
+	case *rbacv1.RoleBinding:
+		subjects = binding.Subjects
+	default:
+		return nil, fmt.Errorf("unexpected binding type %T", obj)
+	}
+
+	var groups []string
+
+	for _, subject := range subjects {
+		if subject.Kind == rbacv1.GroupKind {
+			groups = append(groups, subject.Name)
+		}
+	}
+
+	return groups, nil
+}
+
+// bindingRole indexes a ClusterRoleBinding or RoleBinding by the key of the role it binds.
+func bindingRole(obj any) ([]string, error) {
+	switch binding := obj.(type) {
+	case *rbacv1.ClusterRoleBinding:
+		return []string{roleKey("ClusterRole", "", binding.RoleRef.Name)}, nil
+	case *rbacv1.RoleBinding:
+		return []string{roleKey(binding.RoleRef.Kind, binding.Namespace, binding.RoleRef.Name)}, nil
+	default:
+		return nil, fmt.Errorf("unexpected binding type %T", obj)
+	}
+}
+
+// roleKey returns the key of a ClusterRole, or of a Role in its namespace.
+func roleKey(kind, namespace, name string) string {
+	if kind == "Role" {
+		return kind + "/" + namespace + "/" + name
+	}
+
+	return kind + "/" + name
+}
+
+// getBoundPermissions returns the hub permissions bound to the cluster-specific group and to the
+// group for the entire addon of the managed cluster, from the cached roles and bindings.
+func getBoundPermissions(clients *policyaddon.HubClients, cluster string) ([]HubPermission, error) {
+	rbacInformers := clients.KubeInformers.Rbac().V1()
+
+	type boundRole struct {
+		namespace string
+		binding   string
+		roleRef   rbacv1.RoleRef
+	}
+
+	var boundRoles []boundRole
+
+	// The cluster-specific group and the group for the entire addon
+	for _, group := range agent.DefaultGroups(cluster, addonName)[:2] {
+		clusterRoleBindings, err := rbacInformers.ClusterRoleBindings().Informer().GetIndexer().ByIndex(
+			bindingGroupIndex, group,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the ClusterRoleBindings of the group %s: %w", group, err)
+		}
+
+		for _, obj := range clusterRoleBindings {
+			binding := obj.(*rbacv1.ClusterRoleBinding)
+
+			boundRoles = append(boundRoles, boundRole{"", "ClusterRoleBinding/" + binding.Name, binding.RoleRef})
+		}
+
+		roleBindings, err := rbacInformers.RoleBindings().Informer().GetIndexer().ByIndex(bindingGroupIndex, group)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the RoleBindings of the group %s: %w", group, err)
+		}
+
+		for _, obj := range roleBindings {
+			binding := obj.(*rbacv1.RoleBinding)
+
+			boundRoles = append(boundRoles, boundRole{binding.Namespace, "RoleBinding/" + binding.Name, binding.RoleRef})
+		}
+	}
+
+	// The ClusterRoleBindings come first, then the RoleBindings by namespace
+	slices.SortStableFunc(boundRoles, func(a, b boundRole) int {
+		return cmp.Or(cmp.Compare(a.namespace, b.namespace), cmp.Compare(a.binding, b.binding))
+	})
+
+	permissions := []HubPermission{}
+
+	for i, bound := range boundRoles {
+		// A binding with both groups as subjects is only published once
+		if i > 0 && boundRoles[i-1] == bound {
+			continue
+		}
+
+		var rules []rbacv1.PolicyRule
+
+		if bound.roleRef.Kind == "Role" {
+			role, err := rbacInformers.Roles().Lister().Roles(bound.namespace).Get(bound.roleRef.Name)
+			if err != nil && !k8serrors.IsNotFound(err) {
+				return nil, err
+			}
+
+			if role != nil {
+				rules = role.Rules
+			}
+		} else {
+			clusterRole, err := rbacInformers.ClusterRoles().Lister().Get(bound.roleRef.Name)
+			if err != nil && !k8serrors.IsNotFound(err) {
+				return nil, err
+			}
+
+			if clusterRole != nil {
+				rules = clusterRole.Rules
+			}
+		}
+
+		for _, rule := range rules {
+			permissions = append(permissions, HubPermission{
+				Namespace:  bound.namespace,
+				Binding:    bound.binding,
+				Role:       bound.roleRef.Kind + "/" + bound.roleRef.Name,
+				PolicyRule: rule,
+			})
+		}
+	}
+
+	return permissions, nil
+}
+
+// boundPermissionsSynced returns whether the roles and bindings are cached, so that the hub
+// permissions can be computed.
+func boundPermissionsSynced(clients *policyaddon.HubClients) bool {
+	rbacInformers := clients.KubeInformers.Rbac().V1()
+
+	return rbacInformers.ClusterRoles().Informer().HasSynced() &&
+		rbacInformers.ClusterRoleBindings().Informer().HasSynced() &&
+		rbacInformers.Roles().Informer().HasSynced() &&
+		rbacInformers.RoleBindings().Informer().HasSynced()
+}
+
+// watchBoundPermissions indexes the bindings by group and by role, and triggers the addon of the
+// managed clusters whose bound hub permissions may have changed, so that their info secret is
+// updated. It must be called before the RBAC informers are started.
+func watchBoundPermissions(mgr addonmanager.AddonManager, clients *policyaddon.HubClients) error {
+	rbacInformers := clients.KubeInformers.Rbac().V1()
+	indexers := cache.Indexers{bindingGroupIndex: bindingGroups, bindingRoleIndex: bindingRole}
+
+	bindingInformers := []cache.SharedIndexInformer{
+		rbacInformers.ClusterRoleBindings().Informer(),
+		rbacInformers.RoleBindings().Informer(),
+	}
+
+	for _, informer := range bindingInformers {
+		if err := informer.AddIndexers(indexers); err != nil {
+			return err
+		}
+
+		// The groups of the subjects of the binding
+		_, err := informer.AddEventHandler(triggerBoundClusters(mgr, clients, bindingGroups))
+		if err != nil {
+			return err
+		}
+	}
+
+	roleInformers := []cache.SharedIndexInformer{
+		rbacInformers.ClusterRoles().Informer(),
+		rbacInformers.Roles().Informer(),
+	}
+
+	for _, informer := range roleInformers {
+		// The groups of the subjects of the bindings of the role
+		_, err := informer.AddEventHandler(triggerBoundClusters(mgr, clients, roleGroups(bindingInformers)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// roleGroups returns a function returning the groups of the subjects of the bindings of a
+// ClusterRole or Role, from the indexed binding informers.
+func roleGroups(bindingInformers []cache.SharedIndexInformer) func(obj any) ([]string, error) {
+	return func(obj any) ([]string, error) {
+		var key string
+
+		switch role := obj.(type) {
+		case *rbacv1.ClusterRole:
+			key = roleKey("ClusterRole", "", role.Name)
+		case *rbacv1.Role:
+			key = roleKey("Role", role.Namespace, role.Name)
+		default:
+			return nil, fmt.Errorf("unexpected role type %T", obj)
+		}
+
+		var groups []string
+
+		for _, bindingInformer := range bindingInformers {
+			bindings, err := bindingInformer.GetIndexer().ByIndex(bindingRoleIndex, key)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, binding := range bindings {
+				bound, err := bindingGroups(binding)
+				if err != nil {
+					return nil, err
+				}
+
+				groups = append(groups, bound...)
+			}
+		}
+
+		return groups, nil
+	}
+}
+
+// triggerBoundClusters returns an event handler triggering the addon of the managed clusters
+// whose groups are returned by getGroups for the changed object, or of all the managed clusters
+// with the addon for the group of the entire addon.
+func triggerBoundClusters(
+	mgr addonmanager.AddonManager, clients *policyaddon.HubClients, getGroups func(obj any) ([]string, error),
+) cache.ResourceEventHandler {
+	addonGroup := agent.DefaultGroups("", addonName)[1]
+	// The cluster-specific group, split around a cluster name that can't be in a group name
+	clusterGroupPrefix, clusterGroupSuffix, _ := strings.Cut(agent.DefaultGroups("\x00", addonName)[0], "\x00")
+
+	trigger := func(objs ...any) {
+		clusters := sets.New[string]()
+		allClusters := false
+
+		for _, obj := range objs {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+
+			groups, err := getGroups(obj)
+			if err != nil {
+				log.Error(err, "failed to get the groups bound to the hub permissions")
+
+				continue
+			}
+
+			for _, group := range groups {
+				if group == addonGroup {
+					allClusters = true
+
+					continue
+				}
+
+				cluster, ok := strings.CutPrefix(group, clusterGroupPrefix)
+				if cluster, ok = strings.CutSuffix(cluster, clusterGroupSuffix); ok && cluster != "" {
+					clusters.Insert(cluster)
+				}
+			}
+		}
+
+		triggerBoundAddons(mgr, clients, allClusters, clusters)
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) { trigger(obj) },
+		UpdateFunc: func(oldObj, newObj any) {
+			oldMeta, oldOK := oldObj.(metav1.Object)
+			newMeta, newOK := newObj.(metav1.Object)
+
+			// Skip the periodic resyncs
+			if oldOK && newOK && oldMeta.GetResourceVersion() == newMeta.GetResourceVersion() {
+				return
+			}
+
+			trigger(oldObj, newObj)
+		},
+		DeleteFunc: func(obj any) { trigger(obj) },
+	}
+}
+
+// triggerBoundAddons triggers the addon of the managed clusters, or of all the managed clusters with
+// the addon, so that the hub permissions in their info secret are updated.
+func triggerBoundAddons(
+	mgr addonmanager.AddonManager, clients *policyaddon.HubClients, allClusters bool, clusters sets.Set[string],
+) {
+	if !allClusters && clusters.Len() == 0 {
+		return
+	}
+
+	addons, err := clients.AddonLister.List(labels.Everything())
+	if err != nil {
+		log.Error(err, "failed to list the addons bound to the hub permissions")
+
+		return
+	}
+
+	for _, addon := range addons {
+		if addon.Name == addonName && (allClusters || clusters.Has(addon.Namespace)) {
+			mgr.Trigger(addon.Namespace, addonName)
+		}
+	}
+}
+
+// getRegistrationState returns whether the addon is registered with its hub kubeconfig secret
+// issued on the managed cluster.
+func getRegistrationState(addon *addonapiv1beta1.ManagedClusterAddOn) string {
+	switch {
+	case !meta.IsStatusConditionTrue(addon.Status.Conditions, addonapiv1beta1.ManagedClusterAddOnRegistrationApplied):
+		return registrationPending
+	case !meta.IsStatusConditionTrue(addon.Status.Conditions, policyaddon.ClusterCertificateRotatedCondition):
+		return registrationWaitingForCertificate
+	default:
+		return registrationRegistered
+	}
+}
+
+// getHubInfoValues returns a function setting the values of the info secret: the hub API server
+// and CA, the registration state of the addon, and the hub permissions bound to its groups.
+func getHubInfoValues(clients *policyaddon.HubClients) addonfactory.GetValuesFunc {
+	return func(
+		_ *clusterv1.ManagedCluster,
+		addon *addonapiv1beta1.ManagedClusterAddOn,
+	) (addonfactory.Values, error) {
+		values := addonfactory.Values{
+			"hubAPIServer":    clients.HubAPIServer,
+			"hubCA":           string(clients.HubCA),
+			"hubRegistration": getRegistrationState(addon),
+		}
+
+		// The permissions are only published once the hub controller cached the roles and bindings
+		if boundPermissionsSynced(clients) {
+			permissions, err := getBoundPermissions(clients, addon.Namespace)
+			if err != nil {
+				return nil, err
+			}
+
+			permissionsYAML, err := yaml.Marshal(permissions)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal the hub permissions: %w", err)
+			}
+
+			values["hubPermissions"] = string(permissionsYAML)
+		}
+
+		return values, nil
+	}
+}
@@ -0,0 +1,443 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package standalonetemplating
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	addonlistersv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"sigs.k8s.io/yaml"
+
+	policyaddon "open-cluster-management.io/governance-policy-addon-controller/pkg/addon"
+)
+
+// triggerRecorder records the addons triggered on the manager.
+type triggerRecorder struct {
+	addonmanager.AddonManager
+	lock      sync.Mutex
+	triggered []string
+}
+
+func (r *triggerRecorder) Trigger(clusterName, addonName string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.triggered = append(r.triggered, clusterName+"/"+addonName)
+}
+
+// newInfoTestClients returns the hub clients with the RBAC informers indexed by
+// watchBoundPermissions, synced with the objects unless start is false, and an addon lister
+// holding the addons of this addon on cluster1 and cluster2, and of another addon on cluster3.
+func newInfoTestClients(t *testing.T, start bool, kubeObjects ...runtime.Object) *policyaddon.HubClients {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	addonIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+
+	addons := []*addonapiv1alpha1.ManagedClusterAddOn{
+		{ObjectMeta: metav1.ObjectMeta{Name: addonName, Namespace: "cluster1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: addonName, Namespace: "cluster2"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "other-addon", Namespace: "cluster3"}},
+	}
+
+	for _, addon := range addons {
+		if err := addonIndexer.Add(addon); err != nil {
+			t.Fatalf("expected the addon to be cached, got: %v", err)
+		}
+	}
+
+	kubeInformers := informers.NewSharedInformerFactory(kubefake.NewClientset(kubeObjects...), 0)
+
+	clients := &policyaddon.HubClients{
+		KubeInformers: kubeInformers,
+		AddonLister:   addonlistersv1alpha1.NewManagedClusterAddOnLister(addonIndexer),
+	}
+
+	if err := watchBoundPermissions(&triggerRecorder{}, clients); err != nil {
+		t.Fatalf("expected the hub permissions to be watched, got: %v", err)
+	}
+
+	if start {
+		kubeInformers.Start(ctx.Done())
+		kubeInformers.WaitForCacheSync(ctx.Done())
+	}
+
+	return clients
+}
+
+// The roles and bindings of the hub permissions tests.
+func newBoundRBACObjects() []runtime.Object {
+	clusterGroup := func(cluster string) rbacv1.Subject {
+		return rbacv1.Subject{Kind: rbacv1.GroupKind, Name: agent.DefaultGroups(cluster, addonName)[0]}
+	}
+	addonGroup := rbacv1.Subject{Kind: rbacv1.GroupKind, Name: agent.DefaultGroups("", addonName)[1]}
+	readConfigMaps := rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}
+	readAddons := rbacv1.PolicyRule{
+		APIGroups: []string{"addon.open-cluster-management.io"},
+		Resources: []string{"managedclusteraddons"},
+		Verbs:     []string{"get", "list"},
+	}
+
+	return []runtime.Object{
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "base"}, Rules: []rbacv1.PolicyRule{readAddons}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "reader"}, Rules: []rbacv1.PolicyRule{readConfigMaps}},
+		&rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-reader", Namespace: "app"},
+			Rules:      []rbacv1.PolicyRule{readConfigMaps, readAddons},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "base"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "base"},
+			Subjects:   []rbacv1.Subject{addonGroup},
+		},
+		// Bound to both groups of cluster1
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "reader"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "reader"},
+			Subjects:   []rbacv1.Subject{clusterGroup("cluster1"), addonGroup},
+		},
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "missing"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "missing"},
+			Subjects:   []rbacv1.Subject{clusterGroup("cluster1")},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-reader", Namespace: "app"},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "app-reader"},
+			Subjects:   []rbacv1.Subject{clusterGroup("cluster1")},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "reader", Namespace: "config"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "reader"},
+			Subjects:   []rbacv1.Subject{clusterGroup("cluster2")},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "user", Namespace: "config"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "reader"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "cluster1"}},
+		},
+	}
+}
+
+func TestGetBoundPermissions(t *testing.T) {
+	clients := newInfoTestClients(t, true, newBoundRBACObjects()...)
+
+	tests := map[string]struct {
+		cluster  string
+		expected []string
+	}{
+		"cluster with its own bindings": {
+			cluster: "cluster1",
+			expected: []string{
+				"/ClusterRoleBinding/base/ClusterRole/base/managedclusteraddons",
+				"/ClusterRoleBinding/reader/ClusterRole/reader/configmaps",
+				"app/RoleBinding/app-reader/Role/app-reader/configmaps",
+				"app/RoleBinding/app-reader/Role/app-reader/managedclusteraddons",
+			},
+		},
+		"cluster with a RoleBinding of a ClusterRole": {
+			cluster: "cluster2",
+			expected: []string{
+				"/ClusterRoleBinding/base/ClusterRole/base/managedclusteraddons",
+				"/ClusterRoleBinding/reader/ClusterRole/reader/configmaps",
+				"config/RoleBinding/reader/ClusterRole/reader/configmaps",
+			},
+		},
+		"cluster with the addon group only": {
+			cluster: "cluster3",
+			expected: []string{
+				"/ClusterRoleBinding/base/ClusterRole/base/managedclusteraddons",
+				"/ClusterRoleBinding/reader/ClusterRole/reader/configmaps",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			permissions, err := getBoundPermissions(clients, test.cluster)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			actual := make([]string, 0, len(permissions))
+
+			for _, permission := range permissions {
+				actual = append(actual, strings.Join([]string{
+					permission.Namespace, permission.Binding, permission.Role, permission.Resources[0],
+				}, "/"))
+			}
+
+			if !slices.Equal(actual, test.expected) {
+				t.Fatalf("expected the permissions %v, got: %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestTriggerBoundClusters(t *testing.T) {
+	clients := newInfoTestClients(t, true, newBoundRBACObjects()...)
+	bindingInformers := []cache.SharedIndexInformer{
+		clients.KubeInformers.Rbac().V1().ClusterRoleBindings().Informer(),
+		clients.KubeInformers.Rbac().V1().RoleBindings().Informer(),
+	}
+
+	newBinding := func(subjects ...string) *rbacv1.ClusterRoleBinding {
+		binding := &rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "new"}}
+
+		for _, subject := range subjects {
+			binding.Subjects = append(binding.Subjects, rbacv1.Subject{Kind: rbacv1.GroupKind, Name: subject})
+		}
+
+		return binding
+	}
+
+	tests := map[string]struct {
+		obj      any
+		oldObj   any
+		expected []string
+	}{
+		"binding of a cluster group": {
+			obj:      newBinding(agent.DefaultGroups("cluster1", addonName)[0]),
+			expected: []string{"cluster1"},
+		},
+		"binding of the addon group": {
+			obj:      newBinding(agent.DefaultGroups("", addonName)[1]),
+			expected: []string{"cluster1", "cluster2"},
+		},
+		"binding of a cluster without the addon": {
+			obj: newBinding(agent.DefaultGroups("cluster3", addonName)[0]),
+		},
+		"binding of another addon": {
+			obj: newBinding(agent.DefaultGroups("cluster1", "other-addon")[0]),
+		},
+		"binding of a user": {
+			obj: &rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "user", Namespace: "config"},
+				Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "cluster1"}},
+			},
+		},
+		"updated binding subjects": {
+			oldObj:   newBinding(agent.DefaultGroups("cluster1", addonName)[0]),
+			obj:      newBinding(agent.DefaultGroups("cluster2", addonName)[0]),
+			expected: []string{"cluster1", "cluster2"},
+		},
+		"deleted binding": {
+			obj: cache.DeletedFinalStateUnknown{
+				Key: "new", Obj: newBinding(agent.DefaultGroups("cluster2", addonName)[0]),
+			},
+			expected: []string{"cluster2"},
+		},
+		"ClusterRole bound to the addon group": {
+			obj:      &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "base"}},
+			expected: []string{"cluster1", "cluster2"},
+		},
+		"ClusterRole bound in a namespace": {
+			obj: &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "reader"}},
+			// The ClusterRoleBinding of the addon group and the RoleBinding of cluster2
+			expected: []string{"cluster1", "cluster2"},
+		},
+		"Role bound to a cluster group": {
+			obj:      &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "app-reader", Namespace: "app"}},
+			expected: []string{"cluster1"},
+		},
+		"Role in another namespace": {
+			obj: &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "app-reader", Namespace: "config"}},
+		},
+		"unbound ClusterRole": {
+			obj: &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "unbound"}},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			getGroups := bindingGroups
+
+			switch test.obj.(type) {
+			case *rbacv1.ClusterRole, *rbacv1.Role:
+				getGroups = roleGroups(bindingInformers)
+			}
+
+			recorder := &triggerRecorder{}
+			handler := triggerBoundClusters(recorder, clients, getGroups)
+
+			if test.oldObj != nil {
+				test.oldObj.(metav1.Object).SetResourceVersion("1")
+				test.obj.(metav1.Object).SetResourceVersion("2")
+
+				handler.OnUpdate(test.oldObj, test.obj)
+			} else if _, ok := test.obj.(cache.DeletedFinalStateUnknown); ok {
+				handler.OnDelete(test.obj)
+			} else {
+				handler.OnAdd(test.obj, false)
+			}
+
+			expected := make([]string, 0, len(test.expected))
+
+			for _, cluster := range test.expected {
+				expected = append(expected, cluster+"/"+addonName)
+			}
+
+			slices.Sort(recorder.triggered)
+
+			if !slices.Equal(recorder.triggered, expected) {
+				t.Fatalf("expected the triggered addons %v, got: %v", expected, recorder.triggered)
+			}
+		})
+	}
+
+	t.Run("resync", func(t *testing.T) {
+		recorder := &triggerRecorder{}
+		binding := newBinding(agent.DefaultGroups("cluster1", addonName)[0])
+
+		triggerBoundClusters(recorder, clients, bindingGroups).OnUpdate(binding, binding)
+
+		if len(recorder.triggered) != 0 {
+			t.Fatalf("expected no triggered addon, got: %v", recorder.triggered)
+		}
+	})
+}
+
+// renderInfoSecret renders the info secret of the addon of cluster1 with the values of the
+// descriptor.
+func renderInfoSecret(
+	t *testing.T, clients *policyaddon.HubClients, addon *addonapiv1beta1.ManagedClusterAddOn,
+) *corev1.Secret {
+	t.Helper()
+
+	agentAddon, err := addonfactory.NewAgentAddonFactory(addonName, FS, "manifests/managedclusterchart").
+		WithGetValuesFuncs(Descriptor.MandatedValuesFuncs(clients)...).
+		WithScheme(policyaddon.Scheme).
+		BuildHelmAgentAddon()
+	if err != nil {
+		t.Fatalf("expected the addon to build, got: %v", err)
+	}
+
+	cluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}}
+
+	objects, err := agentAddon.Manifests(context.TODO(), cluster, addon)
+	if err != nil {
+		t.Fatalf("expected the chart to render, got: %v", err)
+	}
+
+	for _, obj := range objects {
+		if secret, ok := obj.(*corev1.Secret); ok {
+			return secret
+		}
+	}
+
+	t.Fatalf("expected the info secret to be rendered, got: %v", objects)
+
+	return nil
+}
+
+func TestInfoSecret(t *testing.T) {
+	registered := []metav1.Condition{
+		{Type: addonapiv1beta1.ManagedClusterAddOnRegistrationApplied, Status: metav1.ConditionTrue, Reason: "Set"},
+		{Type: policyaddon.ClusterCertificateRotatedCondition, Status: metav1.ConditionTrue, Reason: "Rotated"},
+	}
+
+	tests := map[string]struct {
+		synced               bool
+		hubCA                []byte
+		conditions           []metav1.Condition
+		expectedRegistration string
+		expectedPermissions  bool
+	}{
+		"pending registration": {
+			synced:               true,
+			expectedRegistration: registrationPending,
+			expectedPermissions:  true,
+		},
+		"waiting for the certificate": {
+			synced:               true,
+			conditions:           registered[:1],
+			expectedRegistration: registrationWaitingForCertificate,
+			expectedPermissions:  true,
+		},
+		"registered with the hub CA": {
+			synced:               true,
+			hubCA:                []byte("-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"),
+			conditions:           registered,
+			expectedRegistration: registrationRegistered,
+			expectedPermissions:  true,
+		},
+		// The permissions are only published once the roles and bindings are cached
+		"roles and bindings not cached": {
+			conditions:           registered,
+			expectedRegistration: registrationRegistered,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients := newInfoTestClients(t, test.synced, newBoundRBACObjects()...)
+			clients.HubAPIServer = "https://api.hub.example.com:6443"
+			clients.HubCA = test.hubCA
+
+			addon := &addonapiv1beta1.ManagedClusterAddOn{
+				ObjectMeta: metav1.ObjectMeta{Name: addonName, Namespace: "cluster1"},
+				Status:     addonapiv1beta1.ManagedClusterAddOnStatus{Conditions: test.conditions},
+			}
+
+			secret := renderInfoSecret(t, clients, addon)
+
+			expected := map[string]string{
+				"hub.group":        agent.DefaultGroups("cluster1", addonName)[0],
+				"hub.apiServer":    clients.HubAPIServer,
+				"hub.registration": test.expectedRegistration,
+			}
+
+			if test.hubCA != nil {
+				expected["hub.ca.crt"] = string(test.hubCA)
+			}
+
+			permissionsYAML, hasPermissions := secret.StringData["hub.permissions.yaml"]
+			if hasPermissions != test.expectedPermissions {
+				t.Fatalf("expected the hub permissions: %v, got: %v", test.expectedPermissions, secret.StringData)
+			}
+
+			if hasPermissions {
+				var permissions []HubPermission
+
+				if err := yaml.UnmarshalStrict([]byte(permissionsYAML), &permissions); err != nil {
+					t.Fatalf("expected the hub permissions to be valid, got: %v", err)
+				}
+
+				expectedPermissions, err := getBoundPermissions(clients, "cluster1")
+				if err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+
+				if !equality.Semantic.DeepEqual(permissions, expectedPermissions) {
+					t.Fatalf("expected the hub permissions %v, got: %v", expectedPermissions, permissions)
+				}
+
+				delete(secret.StringData, "hub.permissions.yaml")
+			}
+
+			if !equality.Semantic.DeepEqual(secret.StringData, expected) {
+				t.Fatalf("expected the secret data %v, got: %v", expected, secret.StringData)
+			}
+		})
+	}
+}
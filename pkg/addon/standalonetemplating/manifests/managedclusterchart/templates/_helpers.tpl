@@ -51,3 +51,21 @@ Create the name of the service account to use
 {{- define "controller.serviceAccountName" -}}
     {{- template "controller.fullname" . -}}-sa
 {{- end -}}
+
+{{/*
+Create the common labels, set per cluster, to add to the labels of every object
+*/}}
+{{- define "controller.commonLabels" -}}
+    {{- with .Values.global.commonLabels }}
+        {{- toYaml . }}
+    {{- end }}
+{{- end -}}
+
+{{/*
+Create the common annotations, set per cluster, to add to the annotations of every object
+*/}}
+{{- define "controller.commonAnnotations" -}}
+    {{- with .Values.global.commonAnnotations }}
+        {{- toYaml . }}
+    {{- end }}
+{{- end -}}
@@ -0,0 +1,765 @@
+package standalonetemplating
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	clusterlistersv1beta2 "open-cluster-management.io/api/client/cluster/listers/cluster/v1beta2"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	clustersdkv1beta2 "open-cluster-management.io/sdk-go/pkg/apis/cluster/v1beta2"
+
+	policyv1alpha1 "open-cluster-management.io/governance-policy-addon-controller/api/v1alpha1"
+	policyaddon "open-cluster-management.io/governance-policy-addon-controller/pkg/addon"
+)
+
+const (
+	// HubTemplatePermissionLabel is set on the hub RBAC objects created for a HubTemplatePermission,
+	// with its name as the value.
+	HubTemplatePermissionLabel = "policy.open-cluster-management.io/hub-template-permission"
+	// HubTemplatePermissionConditionType is the condition reporting whether the HubTemplatePermission
+	// was reconciled.
+	HubTemplatePermissionConditionType = "Reconciled"
+
+	// permissionsCRDPollInterval is how often the controller checks whether the optional
+	// HubTemplatePermission CRD is installed, until it is.
+	permissionsCRDPollInterval = time.Minute
+	permissionsReconcileKey    = "hubtemplatepermissions"
+	permissionsRolePrefix      = "open-cluster-management:hub-template-permission:"
+)
+
+// HubTemplatePermissionGVR is the resource of the cluster-scoped HubTemplatePermission CRD.
+var HubTemplatePermissionGVR = policyv1alpha1.GroupVersion.WithResource("hubtemplatepermissions")
+
+// readVerbs are the only verbs that a HubTemplatePermission can grant, since hub templates only
+// read hub resources.
+var readVerbs = []string{"get", "list", "watch"}
+
+// validate returns an error when the rules grant more than reading hub resources, grant secrets or
+// wildcard API groups and resources, or are otherwise invalid. These restrictions keep a
+// HubTemplatePermission from granting hub-wide read access, even when it is aggregated to the
+// ClusterRole of the controller.
+func validate(p *policyv1alpha1.HubTemplatePermission) error {
+	var aggregateErr error
+
+	if p.Spec.ClusterSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(p.Spec.ClusterSelector); err != nil {
+			aggregateErr = errors.Join(aggregateErr, fmt.Errorf("invalid cluster selector: %w", err))
+		}
+	}
+
+	for i, rule := range p.Spec.Rules {
+		if len(rule.APIGroups) == 0 || len(rule.Resources) == 0 || len(rule.Verbs) == 0 {
+			aggregateErr = errors.Join(aggregateErr, fmt.Errorf("rule %d must have apiGroups, resources and verbs", i))
+		}
+
+		for _, apiGroup := range rule.APIGroups {
+			if apiGroup == rbacv1.APIGroupAll {
+				aggregateErr = errors.Join(aggregateErr, fmt.Errorf("rule %d can't grant all the API groups", i))
+			}
+		}
+
+		for _, resource := range rule.Resources {
+			if strings.Contains(resource, rbacv1.ResourceAll) {
+				aggregateErr = errors.Join(aggregateErr, fmt.Errorf("rule %d has the wildcard resource '%s'",
+					i, resource))
+			}
+
+			// The secrets would expose the credentials of the hub to the managed clusters
+			if resource == "secrets" || strings.HasPrefix(resource, "secrets/") {
+				aggregateErr = errors.Join(aggregateErr, fmt.Errorf("rule %d can't grant the resource '%s'",
+					i, resource))
+			}
+		}
+
+		for _, verb := range rule.Verbs {
+			if !slices.Contains(readVerbs, verb) {
+				aggregateErr = errors.Join(aggregateErr, fmt.Errorf("rule %d has the verb '%s', expected one of %v",
+					i, verb, readVerbs))
+			}
+		}
+
+		for _, namespace := range rule.Namespaces {
+			if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+				aggregateErr = errors.Join(aggregateErr, fmt.Errorf("rule %d has the invalid namespace '%s': %v",
+					i, namespace, errs))
+			}
+		}
+	}
+
+	return aggregateErr
+}
+
+// clusterSetSelectors returns the cluster selectors of the ManagedClusterSets of the
+// HubTemplatePermission, like the label selector of a global set. The missing sets select no
+// managed clusters.
+func clusterSetSelectors(
+	lister clusterlistersv1beta2.ManagedClusterSetLister, p *policyv1alpha1.HubTemplatePermission,
+) ([]labels.Selector, error) {
+	selectors := make([]labels.Selector, 0, len(p.Spec.ClusterSets))
+
+	for _, name := range p.Spec.ClusterSets {
+		clusterSet, err := lister.Get(name)
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		selector, err := clustersdkv1beta2.BuildClusterSelector(clusterSet)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cluster selector of the ManagedClusterSet %s: %w", name, err)
+		}
+
+		selectors = append(selectors, selector)
+	}
+
+	return selectors, nil
+}
+
+// selects returns whether the HubTemplatePermission selects the managed cluster, either with its
+// cluster selector or with the selectors of its ManagedClusterSets.
+func selects(
+	p *policyv1alpha1.HubTemplatePermission, setSelectors []labels.Selector, cluster *clusterv1.ManagedCluster,
+) bool {
+	clusterLabels := labels.Set(cluster.Labels)
+
+	for _, selector := range setSelectors {
+		if selector.Matches(clusterLabels) {
+			return true
+		}
+	}
+
+	if p.Spec.ClusterSelector == nil {
+		return false
+	}
+
+	// The selector is validated beforehand
+	selector, err := metav1.LabelSelectorAsSelector(p.Spec.ClusterSelector)
+
+	return err == nil && selector.Matches(clusterLabels)
+}
+
+// hubPermissionObjects are the hub RBAC objects of the HubTemplatePermissions.
+type hubPermissionObjects struct {
+	clusterRoles        []*rbacv1.ClusterRole
+	clusterRoleBindings []*rbacv1.ClusterRoleBinding
+	roles               []*rbacv1.Role
+	roleBindings        []*rbacv1.RoleBinding
+}
+
+// desiredObjects returns the ClusterRole and Roles with the rules of the HubTemplatePermission, and
+// their bindings to the groups of the managed clusters.
+func desiredObjects(p *policyv1alpha1.HubTemplatePermission, groups []string) *hubPermissionObjects {
+	objects := &hubPermissionObjects{}
+
+	if len(groups) == 0 {
+		return objects
+	}
+
+	objectMeta := func(namespace string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:      permissionsRolePrefix + p.Name,
+			Namespace: namespace,
+			Labels:    map[string]string{HubTemplatePermissionLabel: p.Name},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: HubTemplatePermissionGVR.GroupVersion().String(),
+				Kind:       "HubTemplatePermission",
+				Name:       p.Name,
+				UID:        p.UID,
+			}},
+		}
+	}
+
+	subjects := make([]rbacv1.Subject, 0, len(groups))
+	for _, group := range groups {
+		subjects = append(subjects, rbacv1.Subject{APIGroup: rbacv1.GroupName, Kind: rbacv1.GroupKind, Name: group})
+	}
+
+	var clusterRules []rbacv1.PolicyRule
+
+	namespaceRules := map[string][]rbacv1.PolicyRule{}
+
+	for _, rule := range p.Spec.Rules {
+		policyRule := rbacv1.PolicyRule{
+			APIGroups:     rule.APIGroups,
+			Resources:     rule.Resources,
+			ResourceNames: rule.ResourceNames,
+			Verbs:         rule.Verbs,
+		}
+
+		if len(rule.Namespaces) == 0 {
+			clusterRules = append(clusterRules, policyRule)
+
+			continue
+		}
+
+		for _, namespace := range rule.Namespaces {
+			namespaceRules[namespace] = append(namespaceRules[namespace], policyRule)
+		}
+	}
+
+	if len(clusterRules) > 0 {
+		objects.clusterRoles = append(objects.clusterRoles, &rbacv1.ClusterRole{
+			ObjectMeta: objectMeta(""),
+			Rules:      clusterRules,
+		})
+		objects.clusterRoleBindings = append(objects.clusterRoleBindings, &rbacv1.ClusterRoleBinding{
+			ObjectMeta: objectMeta(""),
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: permissionsRolePrefix + p.Name,
+			},
+			Subjects: subjects,
+		})
+	}
+
+	for _, namespace := range slices.Sorted(maps.Keys(namespaceRules)) {
+		rules := namespaceRules[namespace]
+		objects.roles = append(objects.roles, &rbacv1.Role{
+			ObjectMeta: objectMeta(namespace),
+			Rules:      rules,
+		})
+		objects.roleBindings = append(objects.roleBindings, &rbacv1.RoleBinding{
+			ObjectMeta: objectMeta(namespace),
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: permissionsRolePrefix + p.Name},
+			Subjects:   subjects,
+		})
+	}
+
+	return objects
+}
+
+// permissionsController reconciles the HubTemplatePermissions when they, the managed clusters, the
+// addons or the hub RBAC change. All the events queue the same key, so that the
+// HubTemplatePermissions are reconciled together, once per burst of events.
+type permissionsController struct {
+	clients *policyaddon.HubClients
+	queue   workqueue.TypedRateLimitingInterface[string]
+	// permissionLister is only set once the optional HubTemplatePermission CRD is served.
+	permissionLister atomic.Pointer[cache.GenericLister]
+}
+
+// enqueue is the event handler of the informers, which queues the reconciliation.
+func (c *permissionsController) enqueue() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(_ any) { c.queue.Add(permissionsReconcileKey) },
+		UpdateFunc: func(_, _ any) { c.queue.Add(permissionsReconcileKey) },
+		DeleteFunc: func(_ any) { c.queue.Add(permissionsReconcileKey) },
+	}
+}
+
+// runPermissionsController reconciles the HubTemplatePermissions, and then refreshes the hub
+// permissions published in the info secrets, on the events of the informers until the context is
+// canceled.
+func runPermissionsController(ctx context.Context, mgr addonmanager.AddonManager, clients *policyaddon.HubClients) {
+	c := &permissionsController{
+		clients: clients,
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "hubtemplatepermissions"},
+		),
+	}
+
+	rbacInformers := clients.KubeInformers.Rbac().V1()
+	addonInformer := clients.AddonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer()
+
+	informers := []cache.SharedIndexInformer{
+		rbacInformers.ClusterRoles().Informer(),
+		rbacInformers.ClusterRoleBindings().Informer(),
+		rbacInformers.Roles().Informer(),
+		rbacInformers.RoleBindings().Informer(),
+		clients.ClusterInformers.Cluster().V1().ManagedClusters().Informer(),
+		clients.ClusterInformers.Cluster().V1beta2().ManagedClusterSets().Informer(),
+	}
+
+	for _, informer := range informers {
+		if _, err := informer.AddEventHandler(c.enqueue()); err != nil {
+			log.Error(err, "failed to watch the resources of the HubTemplatePermissions")
+
+			return
+		}
+	}
+
+	// Only the addons of this addon select the managed clusters
+	_, err := addonInformer.AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: func(obj any) bool {
+			metaObj, err := meta.Accessor(obj)
+			if err != nil {
+				// A tombstone of a deleted addon
+				return true
+			}
+
+			return metaObj.GetName() == addonName
+		},
+		Handler: c.enqueue(),
+	})
+	if err != nil {
+		log.Error(err, "failed to watch the addons of the HubTemplatePermissions")
+
+		return
+	}
+
+	if err := watchBoundPermissions(mgr, clients); err != nil {
+		log.Error(err, "failed to watch the hub permissions of the info secrets")
+
+		return
+	}
+
+	// Run the RBAC and ManagedClusterSet informers requested above
+	clients.KubeInformers.Start(ctx.Done())
+	clients.ClusterInformers.Start(ctx.Done())
+
+	for informerType, synced := range clients.KubeInformers.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			log.Info("The informer wasn't synced", "type", informerType)
+
+			return
+		}
+	}
+
+	for informerType, synced := range clients.ClusterInformers.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			log.Info("The informer wasn't synced", "type", informerType)
+
+			return
+		}
+	}
+
+	// Publish the hub permissions in the info secrets rendered before the roles and bindings were cached
+	triggerBoundAddons(mgr, clients, true, nil)
+
+	go c.watchPermissions(ctx)
+
+	go func() {
+		<-ctx.Done()
+		c.queue.ShutDown()
+	}()
+
+	// The first reconciliation is queued once the HubTemplatePermissions are cached
+	for c.processNext(ctx) {
+	}
+}
+
+// watchPermissions starts the HubTemplatePermission informer once its CRD is served, since the CRD
+// is optional.
+func (c *permissionsController) watchPermissions(ctx context.Context) {
+	gv := HubTemplatePermissionGVR.GroupVersion().String()
+
+	err := wait.PollUntilContextCancel(ctx, permissionsCRDPollInterval, true, func(ctx context.Context) (bool, error) {
+		resources, err := c.clients.KubeClient.Discovery().ServerResourcesForGroupVersion(gv)
+		if err != nil {
+			if !k8serrors.IsNotFound(err) {
+				log.Error(err, "failed to discover the HubTemplatePermission CRD")
+			}
+
+			return false, nil
+		}
+
+		return slices.ContainsFunc(resources.APIResources, func(resource metav1.APIResource) bool {
+			return resource.Name == HubTemplatePermissionGVR.Resource
+		}), nil
+	})
+	if err != nil {
+		// The context is canceled
+		return
+	}
+
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(c.clients.DynamicClient, 10*time.Minute)
+	informer := factory.ForResource(HubTemplatePermissionGVR)
+
+	if _, err := informer.Informer().AddEventHandler(c.enqueue()); err != nil {
+		log.Error(err, "failed to watch the HubTemplatePermissions")
+
+		return
+	}
+
+	factory.Start(ctx.Done())
+
+	for _, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return
+		}
+	}
+
+	lister := informer.Lister()
+	c.permissionLister.Store(&lister)
+	c.queue.Add(permissionsReconcileKey)
+}
+
+// processNext reconciles the HubTemplatePermissions, and returns false once the queue is shut down.
+func (c *permissionsController) processNext(ctx context.Context) bool {
+	key, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+
+	defer c.queue.Done(key)
+
+	if err := c.reconcile(ctx); err != nil {
+		log.Error(err, "failed to reconcile the HubTemplatePermissions")
+		c.queue.AddRateLimited(key)
+
+		return true
+	}
+
+	c.queue.Forget(key)
+
+	return true
+}
+
+// reconcile creates the hub RBAC objects of the HubTemplatePermissions for the groups of the
+// managed clusters with the addon, and empties the ones that are no longer needed. Nothing is done
+// until the HubTemplatePermissions are cached, since the objects of the HubTemplatePermissions
+// deleted with their CRD are garbage collected through their owner references.
+func (c *permissionsController) reconcile(ctx context.Context) error {
+	lister := c.permissionLister.Load()
+	if lister == nil {
+		return nil
+	}
+
+	permissions, err := (*lister).List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list the HubTemplatePermissions: %w", err)
+	}
+
+	clusters, err := c.clients.ClusterLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	addons, err := c.clients.AddonLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	clusterSetLister := c.clients.ClusterInformers.Cluster().V1beta2().ManagedClusterSets().Lister()
+
+	withAddon := map[string]bool{}
+
+	for _, addon := range addons {
+		if addon.Name == addonName {
+			withAddon[addon.Namespace] = true
+		}
+	}
+
+	desired := &hubPermissionObjects{}
+
+	var aggregateErr error
+
+	for _, obj := range permissions {
+		unstructuredObj, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+
+		permission := &policyv1alpha1.HubTemplatePermission{}
+
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredObj.Object, permission)
+		if err != nil {
+			aggregateErr = errors.Join(aggregateErr, fmt.Errorf("failed to parse the HubTemplatePermission %s: %w",
+				unstructuredObj.GetName(), err))
+
+			continue
+		}
+
+		var groups []string
+
+		var setSelectors []labels.Selector
+
+		var applyErr error
+
+		validationErr := validate(permission)
+		if validationErr == nil {
+			setSelectors, validationErr = clusterSetSelectors(clusterSetLister, permission)
+		}
+
+		if validationErr == nil {
+			for _, cluster := range clusters {
+				if withAddon[cluster.Name] && selects(permission, setSelectors, cluster) {
+					groups = append(groups, agent.DefaultGroups(cluster.Name, addonName)[0])
+				}
+			}
+
+			slices.Sort(groups)
+
+			objects := desiredObjects(permission, groups)
+			desired.clusterRoles = append(desired.clusterRoles, objects.clusterRoles...)
+			desired.clusterRoleBindings = append(desired.clusterRoleBindings, objects.clusterRoleBindings...)
+			desired.roles = append(desired.roles, objects.roles...)
+			desired.roleBindings = append(desired.roleBindings, objects.roleBindings...)
+
+			// The status reports whether the objects were applied
+			applyErr = applyPermissionObjects(ctx, c.clients, objects)
+			aggregateErr = errors.Join(aggregateErr, applyErr)
+		}
+
+		err = updatePermissionStatus(ctx, c.clients, unstructuredObj, permission, len(groups), validationErr, applyErr)
+		aggregateErr = errors.Join(aggregateErr, err)
+	}
+
+	return errors.Join(aggregateErr, revokePermissionObjects(ctx, c.clients, desired))
+}
+
+// permissionCondition returns the Reconciled condition of the HubTemplatePermission. It is False
+// when the HubTemplatePermission is invalid, when its hub RBAC objects failed to be applied, or when
+// it has neither a cluster selector nor cluster sets, so that it doesn't silently select no managed
+// clusters.
+func permissionCondition(
+	permission *policyv1alpha1.HubTemplatePermission, clusters int, validationErr error, applyErr error,
+) metav1.Condition {
+	condition := metav1.Condition{
+		Type:    HubTemplatePermissionConditionType,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Reconciled",
+		Message: fmt.Sprintf("The permissions are granted to %d managed clusters", clusters),
+	}
+
+	switch {
+	case validationErr != nil:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Invalid"
+		condition.Message = validationErr.Error()
+	case applyErr != nil:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ApplyFailed"
+		condition.Message = "Failed to apply the hub RBAC objects: " + applyErr.Error()
+	case permission.Spec.ClusterSelector == nil && len(permission.Spec.ClusterSets) == 0:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "NoClusterSelector"
+		condition.Message = "No managed clusters are selected without a clusterSelector or clusterSets, " +
+			"use an empty clusterSelector to select all of them"
+	}
+
+	return condition
+}
+
+// updatePermissionStatus reports the number of managed clusters granted the permissions, and
+// whether the HubTemplatePermission is valid and its hub RBAC objects were applied, in its status.
+func updatePermissionStatus(
+	ctx context.Context,
+	clients *policyaddon.HubClients,
+	obj *unstructured.Unstructured,
+	permission *policyv1alpha1.HubTemplatePermission,
+	clusters int,
+	validationErr error,
+	applyErr error,
+) error {
+	// No managed clusters are known to be granted the permissions when the objects failed to apply
+	if applyErr != nil {
+		clusters = 0
+	}
+
+	status := policyv1alpha1.HubTemplatePermissionStatus{
+		Clusters:   int32(clusters), //nolint:gosec // The number of managed clusters is small
+		Conditions: slices.Clone(permission.Status.Conditions),
+	}
+
+	meta.SetStatusCondition(&status.Conditions, permissionCondition(permission, clusters, validationErr, applyErr))
+
+	if equality.Semantic.DeepEqual(status, permission.Status) {
+		return nil
+	}
+
+	statusObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&status)
+	if err != nil {
+		return err
+	}
+
+	obj = obj.DeepCopy()
+	obj.Object["status"] = statusObj
+
+	_, err = clients.DynamicClient.Resource(HubTemplatePermissionGVR).UpdateStatus(ctx, obj, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update the status of the HubTemplatePermission %s: %w", obj.GetName(), err)
+	}
+
+	return nil
+}
+
+// applyPermissionObjects creates the hub RBAC objects, or updates them when they differ from the
+// cached ones.
+func applyPermissionObjects(
+	ctx context.Context, clients *policyaddon.HubClients, desired *hubPermissionObjects,
+) error {
+	rbacClient := clients.KubeClient.RbacV1()
+	rbacInformers := clients.KubeInformers.Rbac().V1()
+
+	var aggregateErr error
+
+	for _, role := range desired.clusterRoles {
+		existing, err := rbacInformers.ClusterRoles().Lister().Get(role.Name)
+		if k8serrors.IsNotFound(err) {
+			_, err = rbacClient.ClusterRoles().Create(ctx, role, metav1.CreateOptions{})
+		} else if err == nil && !equality.Semantic.DeepEqual(existing.Rules, role.Rules) {
+			existing = existing.DeepCopy()
+			existing.Rules = role.Rules
+			_, err = rbacClient.ClusterRoles().Update(ctx, existing, metav1.UpdateOptions{})
+		}
+
+		aggregateErr = errors.Join(aggregateErr, err)
+	}
+
+	for _, binding := range desired.clusterRoleBindings {
+		existing, err := rbacInformers.ClusterRoleBindings().Lister().Get(binding.Name)
+		if k8serrors.IsNotFound(err) {
+			_, err = rbacClient.ClusterRoleBindings().Create(ctx, binding, metav1.CreateOptions{})
+		} else if err == nil && !equality.Semantic.DeepEqual(existing.Subjects, binding.Subjects) {
+			existing = existing.DeepCopy()
+			existing.Subjects = binding.Subjects
+			_, err = rbacClient.ClusterRoleBindings().Update(ctx, existing, metav1.UpdateOptions{})
+		}
+
+		aggregateErr = errors.Join(aggregateErr, err)
+	}
+
+	for _, role := range desired.roles {
+		existing, err := rbacInformers.Roles().Lister().Roles(role.Namespace).Get(role.Name)
+		if k8serrors.IsNotFound(err) {
+			_, err = rbacClient.Roles(role.Namespace).Create(ctx, role, metav1.CreateOptions{})
+		} else if err == nil && !equality.Semantic.DeepEqual(existing.Rules, role.Rules) {
+			existing = existing.DeepCopy()
+			existing.Rules = role.Rules
+			_, err = rbacClient.Roles(role.Namespace).Update(ctx, existing, metav1.UpdateOptions{})
+		}
+
+		aggregateErr = errors.Join(aggregateErr, err)
+	}
+
+	for _, binding := range desired.roleBindings {
+		existing, err := rbacInformers.RoleBindings().Lister().RoleBindings(binding.Namespace).Get(binding.Name)
+		if k8serrors.IsNotFound(err) {
+			_, err = rbacClient.RoleBindings(binding.Namespace).Create(ctx, binding, metav1.CreateOptions{})
+		} else if err == nil && !equality.Semantic.DeepEqual(existing.Subjects, binding.Subjects) {
+			existing = existing.DeepCopy()
+			existing.Subjects = binding.Subjects
+			_, err = rbacClient.RoleBindings(binding.Namespace).Update(ctx, existing, metav1.UpdateOptions{})
+		}
+
+		aggregateErr = errors.Join(aggregateErr, err)
+	}
+
+	return aggregateErr
+}
+
+// keys returns the kind, namespace and name of each object, for finding the objects to revoke.
+func (o *hubPermissionObjects) keys() map[string]bool {
+	keys := map[string]bool{}
+
+	for _, role := range o.clusterRoles {
+		keys["ClusterRole//"+role.Name] = true
+	}
+
+	for _, binding := range o.clusterRoleBindings {
+		keys["ClusterRoleBinding//"+binding.Name] = true
+	}
+
+	for _, role := range o.roles {
+		keys["Role/"+role.Namespace+"/"+role.Name] = true
+	}
+
+	for _, binding := range o.roleBindings {
+		keys["RoleBinding/"+binding.Namespace+"/"+binding.Name] = true
+	}
+
+	return keys
+}
+
+// revokePermissionObjects empties the cached hub RBAC objects with the HubTemplatePermissionLabel
+// that aren't desired, like the ones of a namespace removed from a rule or of an invalid
+// HubTemplatePermission. They aren't deleted, so that the controller doesn't need to delete any
+// role or binding of the hub, and are garbage collected with their HubTemplatePermission.
+func revokePermissionObjects(
+	ctx context.Context, clients *policyaddon.HubClients, desired *hubPermissionObjects,
+) error {
+	rbacClient := clients.KubeClient.RbacV1()
+	rbacInformers := clients.KubeInformers.Rbac().V1()
+	desiredKeys := desired.keys()
+
+	selector, err := labels.Parse(HubTemplatePermissionLabel)
+	if err != nil {
+		return err
+	}
+
+	var aggregateErr error
+
+	clusterRoles, err := rbacInformers.ClusterRoles().Lister().List(selector)
+	aggregateErr = errors.Join(aggregateErr, err)
+
+	for _, role := range clusterRoles {
+		if !desiredKeys["ClusterRole//"+role.Name] && len(role.Rules) != 0 {
+			role = role.DeepCopy()
+			role.Rules = nil
+			_, err := rbacClient.ClusterRoles().Update(ctx, role, metav1.UpdateOptions{})
+			aggregateErr = errors.Join(aggregateErr, ignoreNotFound(err))
+		}
+	}
+
+	clusterRoleBindings, err := rbacInformers.ClusterRoleBindings().Lister().List(selector)
+	aggregateErr = errors.Join(aggregateErr, err)
+
+	for _, binding := range clusterRoleBindings {
+		if !desiredKeys["ClusterRoleBinding//"+binding.Name] && len(binding.Subjects) != 0 {
+			binding = binding.DeepCopy()
+			binding.Subjects = nil
+			_, err := rbacClient.ClusterRoleBindings().Update(ctx, binding, metav1.UpdateOptions{})
+			aggregateErr = errors.Join(aggregateErr, ignoreNotFound(err))
+		}
+	}
+
+	roles, err := rbacInformers.Roles().Lister().List(selector)
+	aggregateErr = errors.Join(aggregateErr, err)
+
+	for _, role := range roles {
+		if !desiredKeys["Role/"+role.Namespace+"/"+role.Name] && len(role.Rules) != 0 {
+			role = role.DeepCopy()
+			role.Rules = nil
+			_, err := rbacClient.Roles(role.Namespace).Update(ctx, role, metav1.UpdateOptions{})
+			aggregateErr = errors.Join(aggregateErr, ignoreNotFound(err))
+		}
+	}
+
+	roleBindings, err := rbacInformers.RoleBindings().Lister().List(selector)
+	aggregateErr = errors.Join(aggregateErr, err)
+
+	for _, binding := range roleBindings {
+		if !desiredKeys["RoleBinding/"+binding.Namespace+"/"+binding.Name] && len(binding.Subjects) != 0 {
+			binding = binding.DeepCopy()
+			binding.Subjects = nil
+			_, err := rbacClient.RoleBindings(binding.Namespace).Update(ctx, binding, metav1.UpdateOptions{})
+			aggregateErr = errors.Join(aggregateErr, ignoreNotFound(err))
+		}
+	}
+
+	return aggregateErr
+}
+
+// ignoreNotFound ignores the errors of objects that are already deleted.
+func ignoreNotFound(err error) error {
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+
+	return err
+}
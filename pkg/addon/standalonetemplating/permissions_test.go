@@ -0,0 +1,522 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package standalonetemplating
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonfake "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterinformers "open-cluster-management.io/api/client/cluster/informers/externalversions"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	clusterv1beta2 "open-cluster-management.io/api/cluster/v1beta2"
+
+	policyv1alpha1 "open-cluster-management.io/governance-policy-addon-controller/api/v1alpha1"
+	policyaddon "open-cluster-management.io/governance-policy-addon-controller/pkg/addon"
+)
+
+func TestHubTemplatePermissionValidate(t *testing.T) {
+	tests := map[string]struct {
+		rule        policyv1alpha1.HubTemplatePermissionRule
+		selector    *metav1.LabelSelector
+		expectedErr bool
+	}{
+		"read-only rule": {
+			rule: policyv1alpha1.HubTemplatePermissionRule{
+				APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "list", "watch"},
+				Namespaces: []string{"app-config"},
+			},
+		},
+		"write verb": {
+			rule: policyv1alpha1.HubTemplatePermissionRule{
+				APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "update"},
+			},
+			expectedErr: true,
+		},
+		"missing verbs": {
+			rule:        policyv1alpha1.HubTemplatePermissionRule{APIGroups: []string{""}, Resources: []string{"configmaps"}},
+			expectedErr: true,
+		},
+		"secrets": {
+			rule: policyv1alpha1.HubTemplatePermissionRule{
+				APIGroups: []string{""}, Resources: []string{"configmaps", "secrets"}, Verbs: []string{"get"},
+			},
+			expectedErr: true,
+		},
+		"secrets subresource": {
+			rule: policyv1alpha1.HubTemplatePermissionRule{
+				APIGroups: []string{""}, Resources: []string{"secrets/status"}, Verbs: []string{"get"},
+			},
+			expectedErr: true,
+		},
+		"all resources": {
+			rule: policyv1alpha1.HubTemplatePermissionRule{
+				APIGroups: []string{"apps"}, Resources: []string{"*"}, Verbs: []string{"get"},
+			},
+			expectedErr: true,
+		},
+		"all subresources": {
+			rule: policyv1alpha1.HubTemplatePermissionRule{
+				APIGroups: []string{"apps"}, Resources: []string{"deployments/*"}, Verbs: []string{"get"},
+			},
+			expectedErr: true,
+		},
+		"all API groups": {
+			rule: policyv1alpha1.HubTemplatePermissionRule{
+				APIGroups: []string{"*"}, Resources: []string{"configmaps"}, Verbs: []string{"get"},
+			},
+			expectedErr: true,
+		},
+		"invalid namespace": {
+			rule: policyv1alpha1.HubTemplatePermissionRule{
+				APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"},
+				Namespaces: []string{"App_Config"},
+			},
+			expectedErr: true,
+		},
+		"invalid selector": {
+			rule: policyv1alpha1.HubTemplatePermissionRule{
+				APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"},
+			},
+			selector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "environment", Operator: "Unknown"}},
+			},
+			expectedErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			permission := &policyv1alpha1.HubTemplatePermission{
+				Spec: policyv1alpha1.HubTemplatePermissionSpec{
+					ClusterSelector: test.selector,
+					Rules:           []policyv1alpha1.HubTemplatePermissionRule{test.rule},
+				},
+			}
+
+			err := validate(permission)
+			if (err != nil) != test.expectedErr {
+				t.Fatalf("expected an error: %v, got: %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestSelects(t *testing.T) {
+	clients := newTestClients(t, nil, []runtime.Object{
+		&clusterv1beta2.ManagedClusterSet{ObjectMeta: metav1.ObjectMeta{Name: "production"}},
+		&clusterv1beta2.ManagedClusterSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "edge"},
+			Spec: clusterv1beta2.ManagedClusterSetSpec{
+				ClusterSelector: clusterv1beta2.ManagedClusterSelector{
+					SelectorType:  clusterv1beta2.LabelSelector,
+					LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"environment": "edge"}},
+				},
+			},
+		},
+		&clusterv1beta2.ManagedClusterSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "global"},
+			Spec: clusterv1beta2.ManagedClusterSetSpec{
+				ClusterSelector: clusterv1beta2.ManagedClusterSelector{
+					SelectorType:  clusterv1beta2.LabelSelector,
+					LabelSelector: &metav1.LabelSelector{},
+				},
+			},
+		},
+	}, nil)
+
+	clusterSetLister := clients.ClusterInformers.Cluster().V1beta2().ManagedClusterSets().Lister()
+
+	productionCluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{
+		Name: "cluster1", Labels: map[string]string{clusterv1beta2.ClusterSetLabel: "production"},
+	}}
+	edgeCluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{
+		Name: "cluster2", Labels: map[string]string{"environment": "edge"},
+	}}
+	otherCluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster3"}}
+
+	tests := map[string]struct {
+		spec     policyv1alpha1.HubTemplatePermissionSpec
+		expected []string
+	}{
+		"no selector": {},
+		"empty cluster selector": {
+			spec:     policyv1alpha1.HubTemplatePermissionSpec{ClusterSelector: &metav1.LabelSelector{}},
+			expected: []string{"cluster1", "cluster2", "cluster3"},
+		},
+		"cluster selector": {
+			spec: policyv1alpha1.HubTemplatePermissionSpec{
+				ClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"environment": "edge"}},
+			},
+			expected: []string{"cluster2"},
+		},
+		"exclusive cluster set": {
+			spec:     policyv1alpha1.HubTemplatePermissionSpec{ClusterSets: []string{"production"}},
+			expected: []string{"cluster1"},
+		},
+		"label selector cluster set": {
+			spec:     policyv1alpha1.HubTemplatePermissionSpec{ClusterSets: []string{"edge"}},
+			expected: []string{"cluster2"},
+		},
+		"global cluster set": {
+			spec:     policyv1alpha1.HubTemplatePermissionSpec{ClusterSets: []string{"global"}},
+			expected: []string{"cluster1", "cluster2", "cluster3"},
+		},
+		"missing cluster set": {
+			spec: policyv1alpha1.HubTemplatePermissionSpec{ClusterSets: []string{"missing"}},
+		},
+		"cluster set or cluster selector": {
+			spec: policyv1alpha1.HubTemplatePermissionSpec{
+				ClusterSets:     []string{"production"},
+				ClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"environment": "edge"}},
+			},
+			expected: []string{"cluster1", "cluster2"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			permission := &policyv1alpha1.HubTemplatePermission{Spec: test.spec}
+
+			setSelectors, err := clusterSetSelectors(clusterSetLister, permission)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			var selected []string
+
+			for _, cluster := range []*clusterv1.ManagedCluster{productionCluster, edgeCluster, otherCluster} {
+				if selects(permission, setSelectors, cluster) {
+					selected = append(selected, cluster.Name)
+				}
+			}
+
+			if !slices.Equal(selected, test.expected) {
+				t.Fatalf("expected the clusters %v to be selected, got: %v", test.expected, selected)
+			}
+		})
+	}
+}
+
+func TestDesiredObjects(t *testing.T) {
+	permission := &policyv1alpha1.HubTemplatePermission{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-config", UID: "uid"},
+		Spec: policyv1alpha1.HubTemplatePermissionSpec{
+			Rules: []policyv1alpha1.HubTemplatePermissionRule{
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+				{
+					APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"list"},
+					Namespaces: []string{"app2", "app1"},
+				},
+				{
+					APIGroups: []string{""}, Resources: []string{"services"}, Verbs: []string{"get"},
+					Namespaces: []string{"app1"},
+				},
+			},
+		},
+	}
+
+	if objects := desiredObjects(permission, nil); objects.keys()["ClusterRole//"+permissionsRolePrefix+"app-config"] {
+		t.Fatal("expected no objects without groups")
+	}
+
+	groups := []string{"group1", "group2"}
+	objects := desiredObjects(permission, groups)
+
+	if len(objects.clusterRoles) != 1 || len(objects.clusterRoles[0].Rules) != 1 {
+		t.Fatalf("expected a ClusterRole with the rule without namespaces, got: %v", objects.clusterRoles)
+	}
+
+	if len(objects.clusterRoleBindings) != 1 || len(objects.clusterRoleBindings[0].Subjects) != len(groups) {
+		t.Fatalf("expected a ClusterRoleBinding to the groups, got: %v", objects.clusterRoleBindings)
+	}
+
+	namespaces := make([]string, 0, len(objects.roles))
+	for _, role := range objects.roles {
+		namespaces = append(namespaces, role.Namespace)
+	}
+
+	if !slices.Equal(namespaces, []string{"app1", "app2"}) || len(objects.roles[0].Rules) != 2 {
+		t.Fatalf("expected a Role per namespace with its rules, got: %v", objects.roles)
+	}
+
+	if len(objects.roleBindings) != 2 || objects.roleBindings[0].RoleRef.Kind != "Role" {
+		t.Fatalf("expected a RoleBinding per namespace, got: %v", objects.roleBindings)
+	}
+
+	for _, role := range objects.roles {
+		if role.Labels[HubTemplatePermissionLabel] != "app-config" || role.OwnerReferences[0].UID != "uid" {
+			t.Fatalf("expected the Role to be labeled and owned by the HubTemplatePermission, got: %v",
+				role.ObjectMeta)
+		}
+	}
+}
+
+// newTestClients returns the hub clients backed by fake clients with the objects, with the RBAC,
+// cluster and addon informers synced.
+func newTestClients(
+	t *testing.T, kubeObjects []runtime.Object, clusterObjects []runtime.Object, addonObjects []runtime.Object,
+) *policyaddon.HubClients {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	kubeClient := kubefake.NewClientset(kubeObjects...)
+	kubeInformers := informers.NewSharedInformerFactory(kubeClient, 0)
+	rbacInformers := kubeInformers.Rbac().V1()
+
+	rbacInformers.ClusterRoles().Informer()
+	rbacInformers.ClusterRoleBindings().Informer()
+	rbacInformers.Roles().Informer()
+	rbacInformers.RoleBindings().Informer()
+
+	clusterInformers := clusterinformers.NewSharedInformerFactory(clusterfake.NewSimpleClientset(clusterObjects...), 0)
+	clusterInformer := clusterInformers.Cluster().V1().ManagedClusters()
+	clusterInformer.Informer()
+	clusterInformers.Cluster().V1beta2().ManagedClusterSets().Informer()
+
+	addonInformers := addoninformers.NewSharedInformerFactory(addonfake.NewSimpleClientset(addonObjects...), 0)
+	addonInformer := addonInformers.Addon().V1alpha1().ManagedClusterAddOns()
+	addonInformer.Informer()
+
+	kubeInformers.Start(ctx.Done())
+	clusterInformers.Start(ctx.Done())
+	addonInformers.Start(ctx.Done())
+
+	kubeInformers.WaitForCacheSync(ctx.Done())
+	clusterInformers.WaitForCacheSync(ctx.Done())
+	addonInformers.WaitForCacheSync(ctx.Done())
+
+	return &policyaddon.HubClients{
+		KubeClient:       kubeClient,
+		KubeInformers:    kubeInformers,
+		ClusterInformers: clusterInformers,
+		AddonInformers:   addonInformers,
+		ClusterLister:    clusterInformer.Lister(),
+		AddonLister:      addonInformer.Lister(),
+	}
+}
+
+func TestReconcilePermissions(t *testing.T) {
+	permission := &policyv1alpha1.HubTemplatePermission{
+		TypeMeta:   metav1.TypeMeta{APIVersion: policyv1alpha1.GroupVersion.String(), Kind: "HubTemplatePermission"},
+		ObjectMeta: metav1.ObjectMeta{Name: "app-config"},
+		Spec: policyv1alpha1.HubTemplatePermissionSpec{
+			ClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"environment": "edge"}},
+			Rules: []policyv1alpha1.HubTemplatePermissionRule{{
+				APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"},
+				Namespaces: []string{"app-config"},
+			}},
+		},
+	}
+
+	permissionObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(permission)
+	if err != nil {
+		t.Fatalf("expected the HubTemplatePermission to be converted, got: %v", err)
+	}
+
+	labeled := metav1.ObjectMeta{Labels: map[string]string{HubTemplatePermissionLabel: "deleted"}}
+
+	staleRole := &rbacv1.Role{ObjectMeta: *labeled.DeepCopy()}
+	staleRole.Name = permissionsRolePrefix + "deleted"
+	staleRole.Namespace = "app-config"
+	staleRole.Rules = []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+	}
+
+	otherRole := &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "other"}}
+
+	edgeLabels := map[string]string{"environment": "edge"}
+	clients := newTestClients(t,
+		[]runtime.Object{staleRole, otherRole},
+		[]runtime.Object{
+			&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1", Labels: edgeLabels}},
+			// Without the addon
+			&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster2", Labels: edgeLabels}},
+			&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster3"}},
+		},
+		[]runtime.Object{
+			&addonapiv1alpha1.ManagedClusterAddOn{ObjectMeta: metav1.ObjectMeta{Name: addonName, Namespace: "cluster1"}},
+			&addonapiv1alpha1.ManagedClusterAddOn{ObjectMeta: metav1.ObjectMeta{Name: addonName, Namespace: "cluster3"}},
+		},
+	)
+
+	unstructuredPermission := &unstructured.Unstructured{Object: permissionObj}
+	clients.DynamicClient = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{HubTemplatePermissionGVR: "HubTemplatePermissionList"},
+		unstructuredPermission,
+	)
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := indexer.Add(unstructuredPermission); err != nil {
+		t.Fatalf("expected the HubTemplatePermission to be cached, got: %v", err)
+	}
+
+	controller := &permissionsController{clients: clients}
+	rbacClient := clients.KubeClient.RbacV1()
+
+	// Nothing is revoked until the HubTemplatePermissions are cached
+	if err := controller.reconcile(context.TODO()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	role, err := rbacClient.Roles("app-config").Get(context.TODO(), staleRole.Name, metav1.GetOptions{})
+	if err != nil || len(role.Rules) == 0 {
+		t.Fatalf("expected the Role to be kept before the HubTemplatePermissions are cached, got: %v, %v", role, err)
+	}
+
+	lister := cache.NewGenericLister(indexer, HubTemplatePermissionGVR.GroupResource())
+	controller.permissionLister.Store(&lister)
+
+	if err := controller.reconcile(context.TODO()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	binding, err := rbacClient.RoleBindings("app-config").Get(
+		context.TODO(), permissionsRolePrefix+"app-config", metav1.GetOptions{},
+	)
+	if err != nil {
+		t.Fatalf("expected the RoleBinding to be created, got: %v", err)
+	}
+
+	expectedSubjects := []rbacv1.Subject{{
+		APIGroup: rbacv1.GroupName, Kind: rbacv1.GroupKind, Name: agent.DefaultGroups("cluster1", addonName)[0],
+	}}
+	if !slices.Equal(binding.Subjects, expectedSubjects) {
+		t.Fatalf("expected the RoleBinding to the group of cluster1, got: %v", binding.Subjects)
+	}
+
+	if _, err := rbacClient.Roles("app-config").Get(
+		context.TODO(), permissionsRolePrefix+"app-config", metav1.GetOptions{},
+	); err != nil {
+		t.Fatalf("expected the Role to be created, got: %v", err)
+	}
+
+	// The Role is emptied rather than deleted, since the controller can't delete roles
+	role, err = rbacClient.Roles("app-config").Get(context.TODO(), staleRole.Name, metav1.GetOptions{})
+	if err != nil || len(role.Rules) != 0 {
+		t.Fatalf("expected the Role of the deleted HubTemplatePermission to be emptied, got: %v, %v", role, err)
+	}
+
+	actions := clients.KubeClient.(*kubefake.Clientset).Actions()
+	if slices.ContainsFunc(actions, func(action clienttesting.Action) bool { return action.GetVerb() == "delete" }) {
+		t.Fatal("expected no role or binding to be deleted")
+	}
+
+	if _, err := rbacClient.ClusterRoles().Get(context.TODO(), "other", metav1.GetOptions{}); err != nil {
+		t.Fatalf("expected the unlabeled ClusterRole to be kept, got: %v", err)
+	}
+
+	updated, err := clients.DynamicClient.Resource(HubTemplatePermissionGVR).Get(
+		context.TODO(), "app-config", metav1.GetOptions{},
+	)
+	if err != nil {
+		t.Fatalf("expected the HubTemplatePermission, got: %v", err)
+	}
+
+	clusters, _, _ := unstructured.NestedInt64(updated.Object, "status", "clusters")
+	if clusters != 1 {
+		t.Fatalf("expected the status to count 1 cluster, got: %v", updated.Object["status"])
+	}
+
+	// The status reports the objects that can't be applied, like a Role of a forbidden namespace
+	clients.KubeClient.(*kubefake.Clientset).PrependReactor("create", "roles",
+		func(_ clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, k8serrors.NewForbidden(rbacv1.Resource("roles"), "", errors.New("forbidden"))
+		},
+	)
+
+	permission.Spec.Rules[0].Namespaces = []string{"other-namespace"}
+
+	permissionObj, err = runtime.DefaultUnstructuredConverter.ToUnstructured(permission)
+	if err != nil {
+		t.Fatalf("expected the HubTemplatePermission to be converted, got: %v", err)
+	}
+
+	if err := indexer.Update(&unstructured.Unstructured{Object: permissionObj}); err != nil {
+		t.Fatalf("expected the HubTemplatePermission to be cached, got: %v", err)
+	}
+
+	if err := controller.reconcile(context.TODO()); err == nil {
+		t.Fatal("expected an error when the Role can't be created")
+	}
+
+	updated, err = clients.DynamicClient.Resource(HubTemplatePermissionGVR).Get(
+		context.TODO(), "app-config", metav1.GetOptions{},
+	)
+	if err != nil {
+		t.Fatalf("expected the HubTemplatePermission, got: %v", err)
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(updated.Object, "status", "conditions")
+	if len(conditions) != 1 || conditions[0].(map[string]any)["reason"] != "ApplyFailed" {
+		t.Fatalf("expected the ApplyFailed reason in the status, got: %v", updated.Object["status"])
+	}
+}
+
+func TestPermissionCondition(t *testing.T) {
+	tests := map[string]struct {
+		spec           policyv1alpha1.HubTemplatePermissionSpec
+		validationErr  error
+		applyErr       error
+		expectedStatus metav1.ConditionStatus
+		expectedReason string
+	}{
+		"cluster selector": {
+			spec:           policyv1alpha1.HubTemplatePermissionSpec{ClusterSelector: &metav1.LabelSelector{}},
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: "Reconciled",
+		},
+		"cluster sets": {
+			spec:           policyv1alpha1.HubTemplatePermissionSpec{ClusterSets: []string{"production"}},
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: "Reconciled",
+		},
+		"no cluster selector": {
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: "NoClusterSelector",
+		},
+		"invalid": {
+			spec:           policyv1alpha1.HubTemplatePermissionSpec{ClusterSelector: &metav1.LabelSelector{}},
+			validationErr:  errors.New("invalid rule"),
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: "Invalid",
+		},
+		"apply failed": {
+			spec:           policyv1alpha1.HubTemplatePermissionSpec{ClusterSelector: &metav1.LabelSelector{}},
+			applyErr:       errors.New("forbidden"),
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: "ApplyFailed",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			permission := &policyv1alpha1.HubTemplatePermission{Spec: test.spec}
+
+			condition := permissionCondition(permission, 0, test.validationErr, test.applyErr)
+			if condition.Status != test.expectedStatus || condition.Reason != test.expectedReason {
+				t.Fatalf("expected the Reconciled condition %s with the reason %s, got: %v",
+					test.expectedStatus, test.expectedReason, condition)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package addon
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	"open-cluster-management.io/sdk-go/pkg/patcher"
+)
+
+// ClusterCertificateRotatedCondition is set on a ManagedClusterAddOn by the registration agent of
+// the managed cluster once the client certificate of the hub kubeconfig secret is issued.
+const ClusterCertificateRotatedCondition = "ClusterCertificateRotated"
+
+type addonConditionsKey struct{}
+
+// AddonConditions collects the condition changes of a ManagedClusterAddOn while it is rendered, so
+// that its status is patched once afterwards. Patching it for each change would conflict, since
+// the rendered ManagedClusterAddOn keeps the same resourceVersion.
+type AddonConditions struct {
+	lock    sync.Mutex
+	changes []addonConditionChange
+}
+
+// addonConditionChange sets the condition, or removes the condition type when it is nil.
+type addonConditionChange struct {
+	condition     *metav1.Condition
+	conditionType string
+}
+
+// WithAddonConditions returns a context collecting the condition changes made with
+// SetAddonCondition and RemoveAddonCondition.
+func WithAddonConditions(ctx context.Context) (context.Context, *AddonConditions) {
+	conditions := &AddonConditions{}
+
+	return context.WithValue(ctx, addonConditionsKey{}, conditions), conditions
+}
+
+// SetAddonCondition sets the condition in the status of the ManagedClusterAddOn being rendered.
+// It is ignored when the context doesn't collect the condition changes.
+func SetAddonCondition(ctx context.Context, condition metav1.Condition) {
+	if conditions, ok := ctx.Value(addonConditionsKey{}).(*AddonConditions); ok {
+		conditions.add(addonConditionChange{condition: &condition, conditionType: condition.Type})
+	}
+}
+
+// RemoveAddonCondition removes the condition type from the status of the ManagedClusterAddOn being
+// rendered. It is ignored when the context doesn't collect the condition changes.
+func RemoveAddonCondition(ctx context.Context, conditionType string) {
+	if conditions, ok := ctx.Value(addonConditionsKey{}).(*AddonConditions); ok {
+		conditions.add(addonConditionChange{conditionType: conditionType})
+	}
+}
+
+func (c *AddonConditions) add(change addonConditionChange) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.changes = append(c.changes, change)
+}
+
+// Apply returns a copy of the conditions with the collected changes applied in order.
+func (c *AddonConditions) Apply(conditions []metav1.Condition) []metav1.Condition {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	newConditions := slices.Clone(conditions)
+
+	for _, change := range c.changes {
+		if change.condition != nil {
+			meta.SetStatusCondition(&newConditions, *change.condition)
+		} else {
+			meta.RemoveStatusCondition(&newConditions, change.conditionType)
+		}
+	}
+
+	return newConditions
+}
+
+// patchAddonConditions patches the status of the ManagedClusterAddOn with the collected condition
+// changes, only when the conditions changed.
+func patchAddonConditions(
+	ctx context.Context,
+	addonClient addonv1alpha1client.Interface,
+	addon *addonapiv1beta1.ManagedClusterAddOn,
+	conditions *AddonConditions,
+) error {
+	newStatus := addon.Status.DeepCopy()
+	newStatus.Conditions = conditions.Apply(addon.Status.Conditions)
+
+	addonPatcher := patcher.NewPatcher[
+		*addonapiv1beta1.ManagedClusterAddOn,
+		addonapiv1beta1.ManagedClusterAddOnSpec,
+		addonapiv1beta1.ManagedClusterAddOnStatus,
+	](addonClient.AddonV1beta1().ManagedClusterAddOns(addon.Namespace))
+
+	_, err := addonPatcher.PatchStatus(ctx, addon, *newStatus, addon.Status)
+
+	return err
+}
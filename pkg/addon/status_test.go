@@ -0,0 +1,152 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	addonfake "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+// staticAgentAddon renders the same objects, or error, for every ManagedClusterAddOn.
+type staticAgentAddon struct {
+	objects []runtime.Object
+	err     error
+}
+
+func (s *staticAgentAddon) Manifests(
+	_ context.Context, _ *clusterv1.ManagedCluster, _ *addonapiv1beta1.ManagedClusterAddOn,
+) ([]runtime.Object, error) {
+	return s.objects, s.err
+}
+
+func (s *staticAgentAddon) GetAgentAddonOptions() agent.AgentAddonOptions {
+	return agent.AgentAddonOptions{AddonName: "my-addon"}
+}
+
+// setConditionFunc returns a post-render function setting the condition.
+func setConditionFunc(conditionType string) PostRenderFunc {
+	return func(
+		ctx context.Context,
+		_ *clusterv1.ManagedCluster,
+		_ *addonapiv1beta1.ManagedClusterAddOn,
+		objects []runtime.Object,
+	) ([]runtime.Object, error) {
+		SetAddonCondition(ctx, metav1.Condition{
+			Type: conditionType, Status: metav1.ConditionTrue, Reason: "Test", Message: "set while rendering",
+		})
+
+		return objects, nil
+	}
+}
+
+func TestPolicyAgentAddonPatchesConditionsOnce(t *testing.T) {
+	tests := map[string]struct {
+		renderErr       error
+		expectedPatches int
+	}{
+		"successful render": {expectedPatches: 1},
+		// The conditions set before the error are still reported
+		"failed render": {renderErr: errors.New("render failed"), expectedPatches: 1},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			addon := &addonapiv1beta1.ManagedClusterAddOn{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-addon", Namespace: "cluster1", ResourceVersion: "1"},
+				Status: addonapiv1beta1.ManagedClusterAddOnStatus{
+					Conditions: []metav1.Condition{
+						{Type: "Stale", Status: metav1.ConditionTrue, Reason: "Test"},
+					},
+				},
+			}
+
+			addonClient := addonfake.NewSimpleClientset(addon)
+
+			failingFunc := func(
+				ctx context.Context,
+				_ *clusterv1.ManagedCluster,
+				_ *addonapiv1beta1.ManagedClusterAddOn,
+				objects []runtime.Object,
+			) ([]runtime.Object, error) {
+				RemoveAddonCondition(ctx, "Stale")
+
+				return objects, test.renderErr
+			}
+
+			agentAddon := &PolicyAgentAddon{
+				AgentAddon: &staticAgentAddon{},
+				PostRenderFuncs: []PostRenderFunc{
+					setConditionFunc("First"), setConditionFunc("Second"), failingFunc,
+				},
+				AddonClient: addonClient,
+			}
+
+			_, err := agentAddon.Manifests(context.TODO(), &clusterv1.ManagedCluster{}, addon)
+			if !errors.Is(err, test.renderErr) {
+				t.Fatalf("expected the render error %v, got: %v", test.renderErr, err)
+			}
+
+			patches := 0
+
+			for _, action := range addonClient.Actions() {
+				if action.GetVerb() == "patch" {
+					patches++
+
+					if action.GetSubresource() != "status" {
+						t.Fatalf("expected the status to be patched, got the subresource: %q", action.GetSubresource())
+					}
+				}
+			}
+
+			if patches != test.expectedPatches {
+				t.Fatalf("expected %d patch, got: %d", test.expectedPatches, patches)
+			}
+
+			patched, err := addonClient.AddonV1beta1().ManagedClusterAddOns("cluster1").Get(
+				context.TODO(), "my-addon", metav1.GetOptions{},
+			)
+			if err != nil {
+				t.Fatalf("expected the addon, got: %v", err)
+			}
+
+			for _, conditionType := range []string{"First", "Second"} {
+				if !meta.IsStatusConditionTrue(patched.Status.Conditions, conditionType) {
+					t.Fatalf("expected the %s condition to be set, got: %v", conditionType, patched.Status.Conditions)
+				}
+			}
+
+			if meta.FindStatusCondition(patched.Status.Conditions, "Stale") != nil {
+				t.Fatalf("expected the Stale condition to be removed, got: %v", patched.Status.Conditions)
+			}
+		})
+	}
+}
+
+func TestPolicyAgentAddonSkipsUnchangedConditions(t *testing.T) {
+	addon := &addonapiv1beta1.ManagedClusterAddOn{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-addon", Namespace: "cluster1"},
+	}
+
+	addonClient := addonfake.NewSimpleClientset(addon)
+
+	agentAddon := &PolicyAgentAddon{AgentAddon: &staticAgentAddon{}, AddonClient: addonClient}
+
+	if _, err := agentAddon.Manifests(context.TODO(), &clusterv1.ManagedCluster{}, addon); err != nil {
+		t.Fatalf("expected the addon to render, got: %v", err)
+	}
+
+	for _, action := range addonClient.Actions() {
+		if action.GetVerb() == "patch" {
+			t.Fatalf("expected no patch without condition changes, got: %v", action)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+{{/* Copyright Contributors to the Open Cluster Management project */}}
+
+{{- define "controller.labels" -}}
+app: {{ .Release.Name }}
+chart: {{ .Chart.Name }}-{{ .Chart.Version }}
+{{- end }}
@@ -0,0 +1,300 @@
+package addon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	configv1client "github.com/openshift/client-go/config/clientset/versioned"
+	"github.com/openshift/library-go/pkg/crypto"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	sdktls "open-cluster-management.io/sdk-go/pkg/tls"
+)
+
+// TLSProfileConditionType is the ManagedClusterAddOn condition reporting the effective TLS
+// settings of the agent and where they come from.
+const TLSProfileConditionType = "TLSProfile"
+
+// The reasons of the TLSProfile condition.
+const (
+	TLSProfileReasonAddOnDeploymentConfig = "AddOnDeploymentConfig"
+	TLSProfileReasonControllerConfig      = "ControllerConfig"
+	TLSProfileReasonHub                   = "HubTLSProfile"
+	TLSProfileReasonAgentDefault          = "AgentDefault"
+)
+
+// tlsProfileRefreshInterval is how often the hub TLS profile is read again.
+const tlsProfileRefreshInterval = time.Minute
+
+// TLSProfile contains the TLS settings passed to the agents in the tlsMinVersion and
+// tlsCipherSuites values. Empty settings keep the defaults of the agents.
+type TLSProfile struct {
+	MinVersion   string
+	CipherSuites string
+	// Source describes where the profile comes from, and is reported in the addon status.
+	Source string
+}
+
+// HubTLSProfile is the hub-wide TLS profile used by default for the agents. It is read from the
+// ocm-tls-profile ConfigMap in the controller namespace when it exists, and otherwise from the
+// tlsSecurityProfile of the OpenShift APIServer. A nil HubTLSProfile has no profile.
+type HubTLSProfile struct {
+	kubeClient   kubernetes.Interface
+	configClient configv1client.Interface
+	namespace    string
+
+	lock    sync.RWMutex
+	profile *TLSProfile
+}
+
+// NewHubTLSProfile returns a HubTLSProfile reading the ConfigMap in the namespace. The profile
+// is empty until Refresh is called.
+func NewHubTLSProfile(
+	kubeClient kubernetes.Interface, configClient configv1client.Interface, namespace string,
+) *HubTLSProfile {
+	return &HubTLSProfile{kubeClient: kubeClient, configClient: configClient, namespace: namespace}
+}
+
+// Get returns the hub TLS profile, or nil when the hub doesn't configure one.
+func (h *HubTLSProfile) Get() *TLSProfile {
+	if h == nil {
+		return nil
+	}
+
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	return h.profile
+}
+
+// Refresh reads the hub TLS profile again and returns whether it changed.
+func (h *HubTLSProfile) Refresh(ctx context.Context) (bool, error) {
+	profile, err := h.load(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.profile == nil && profile == nil || h.profile != nil && profile != nil && *h.profile == *profile {
+		return false, nil
+	}
+
+	h.profile = profile
+
+	return true, nil
+}
+
+// Run refreshes the hub TLS profile periodically until the context is canceled, and calls
+// onChange when it changes.
+func (h *HubTLSProfile) Run(ctx context.Context, onChange func()) {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		changed, err := h.Refresh(ctx)
+		if err != nil {
+			log.Error(err, "failed to refresh the hub TLS profile, keeping the current one")
+
+			return
+		}
+
+		if !changed {
+			return
+		}
+
+		if profile := h.Get(); profile != nil {
+			log.Info("The hub TLS profile changed", "source", profile.Source,
+				"minVersion", profile.MinVersion, "cipherSuites", profile.CipherSuites)
+		} else {
+			log.Info("The hub TLS profile was removed, the agents use their default TLS settings")
+		}
+
+		onChange()
+	}, tlsProfileRefreshInterval)
+}
+
+func (h *HubTLSProfile) load(ctx context.Context) (*TLSProfile, error) {
+	if h.namespace != "" {
+		tlsConfig, err := sdktls.LoadTLSConfigFromConfigMap(ctx, h.kubeClient, h.namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		if tlsConfig != nil {
+			return &TLSProfile{
+				MinVersion:   sdktls.VersionToString(tlsConfig.MinVersion),
+				CipherSuites: sdktls.CipherSuitesToString(tlsConfig.CipherSuites),
+				Source:       fmt.Sprintf("ConfigMap %s/%s", h.namespace, sdktls.ConfigMapName),
+			}, nil
+		}
+	}
+
+	apiServer, err := h.configClient.ConfigV1().APIServers().Get(ctx, "cluster", metav1.GetOptions{})
+	if err != nil {
+		// The APIServer only exists on OpenShift
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get the APIServer cluster: %w", err)
+	}
+
+	return tlsProfileFromSecurityProfile(apiServer.Spec.TLSSecurityProfile), nil
+}
+
+// tlsProfileFromSecurityProfile converts an OpenShift TLS security profile, which defaults to the
+// Intermediate profile. The OpenSSL cipher names are converted to the IANA names used by the
+// agents, and the ciphers that Go doesn't support are left out.
+func tlsProfileFromSecurityProfile(securityProfile *configv1.TLSSecurityProfile) *TLSProfile {
+	profileType := configv1.TLSProfileIntermediateType
+	if securityProfile != nil && securityProfile.Type != "" {
+		profileType = securityProfile.Type
+	}
+
+	var spec *configv1.TLSProfileSpec
+
+	if profileType == configv1.TLSProfileCustomType {
+		if securityProfile.Custom != nil {
+			spec = &securityProfile.Custom.TLSProfileSpec
+		}
+	} else {
+		spec = configv1.TLSProfiles[profileType]
+	}
+
+	if spec == nil {
+		profileType = configv1.TLSProfileIntermediateType
+		spec = configv1.TLSProfiles[profileType]
+	}
+
+	cipherSuites, _ := sdktls.ParseCipherSuites(strings.Join(crypto.OpenSSLToIANACipherSuites(spec.Ciphers), ","))
+
+	return &TLSProfile{
+		MinVersion:   string(spec.MinTLSVersion),
+		CipherSuites: sdktls.CipherSuitesToString(cipherSuites),
+		Source:       fmt.Sprintf("the %s tlsSecurityProfile of the APIServer cluster", profileType),
+	}
+}
+
+// getHubTLSProfileValues returns the tlsMinVersion and tlsCipherSuites values of the hub TLS
+// profile. They are overridden by the values annotation and the AddOnDeploymentConfig.
+func getHubTLSProfileValues(hubProfile *HubTLSProfile) addonfactory.GetValuesFunc {
+	return func(
+		_ *clusterv1.ManagedCluster,
+		_ *addonapiv1beta1.ManagedClusterAddOn,
+	) (addonfactory.Values, error) {
+		values := addonfactory.Values{}
+
+		profile := hubProfile.Get()
+		if profile == nil {
+			return values, nil
+		}
+
+		if profile.MinVersion != "" {
+			values["tlsMinVersion"] = profile.MinVersion
+		}
+
+		if profile.CipherSuites != "" {
+			values["tlsCipherSuites"] = profile.CipherSuites
+		}
+
+		return values, nil
+	}
+}
+
+// effectiveTLSProfile returns the TLS settings of the agent and the reason of the TLSProfile
+// condition. The valid default customized variables of the controller configuration override each
+// setting of the hub TLS profile, and the ones of the AddOnDeploymentConfig override both.
+func effectiveTLSProfile(
+	hubProfile *TLSProfile, defaults map[string]string, config *addonapiv1beta1.AddOnDeploymentConfig,
+) (minVersion, cipherSuites, reason string) {
+	minVersionSource, cipherSuitesSource := "the agent default", "the agent default"
+	reason = TLSProfileReasonAgentDefault
+
+	if hubProfile != nil {
+		if hubProfile.MinVersion != "" {
+			minVersion, minVersionSource = hubProfile.MinVersion, hubProfile.Source
+			reason = TLSProfileReasonHub
+		}
+
+		if hubProfile.CipherSuites != "" {
+			cipherSuites, cipherSuitesSource = hubProfile.CipherSuites, hubProfile.Source
+			reason = TLSProfileReasonHub
+		}
+	}
+
+	setFromVariables := func(variables map[string]string, source, variablesReason string) {
+		cv := &CommonValues{}
+
+		if value, ok := variables["tlsMinVersion"]; ok && cv.SetTLSMinVersion(value) == nil {
+			minVersion, minVersionSource = value, source
+			reason = variablesReason
+		}
+
+		if value, ok := variables["tlsCipherSuites"]; ok && cv.SetTLSCipherSuites(value) == nil {
+			cipherSuites, cipherSuitesSource = value, source
+			reason = variablesReason
+		}
+	}
+
+	setFromVariables(defaults, "the controller configuration", TLSProfileReasonControllerConfig)
+
+	if config != nil {
+		variables := map[string]string{}
+		for _, variable := range config.Spec.CustomizedVariables {
+			variables[variable.Name] = variable.Value
+		}
+
+		setFromVariables(variables, fmt.Sprintf("AddOnDeploymentConfig %s/%s", config.Namespace, config.Name),
+			TLSProfileReasonAddOnDeploymentConfig)
+	}
+
+	if minVersion != "" {
+		minVersionSource = minVersion + " from " + minVersionSource
+	}
+
+	if cipherSuites != "" {
+		cipherSuitesSource = cipherSuites + " from " + cipherSuitesSource
+	}
+
+	return minVersionSource, cipherSuitesSource, reason
+}
+
+// getTLSProfileConditionFunc returns a post-render function that reports the effective TLS
+// settings of the agent in the TLSProfile condition of the ManagedClusterAddOn. It doesn't
+// change the rendered objects.
+func getTLSProfileConditionFunc(clients *HubClients) PostRenderFunc {
+	return func(
+		ctx context.Context,
+		_ *clusterv1.ManagedCluster,
+		addon *addonapiv1beta1.ManagedClusterAddOn,
+		objects []runtime.Object,
+	) ([]runtime.Object, error) {
+		config, err := utils.GetDesiredAddOnDeploymentConfig(addon, clients.ADCGetter)
+		if err != nil {
+			return nil, err
+		}
+
+		minVersion, cipherSuites, reason := effectiveTLSProfile(
+			clients.TLSProfile.Get(), GetControllerConfig().CustomizedVariables, config,
+		)
+
+		SetAddonCondition(ctx, metav1.Condition{
+			Type:    TLSProfileConditionType,
+			Status:  metav1.ConditionTrue,
+			Reason:  reason,
+			Message: fmt.Sprintf("Minimum TLS version: %s; cipher suites: %s", minVersion, cipherSuites),
+		})
+
+		return objects, nil
+	}
+}
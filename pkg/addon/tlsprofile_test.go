@@ -0,0 +1,139 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	configfake "github.com/openshift/client-go/config/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	addonapiv1beta1 "open-cluster-management.io/api/addon/v1beta1"
+	sdktls "open-cluster-management.io/sdk-go/pkg/tls"
+)
+
+func TestHubTLSProfileRefresh(t *testing.T) {
+	apiServer := &configv1.APIServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Spec: configv1.APIServerSpec{
+			TLSSecurityProfile: &configv1.TLSSecurityProfile{
+				Type:   configv1.TLSProfileModernType,
+				Modern: &configv1.ModernTLSProfile{},
+			},
+		},
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: sdktls.ConfigMapName, Namespace: "open-cluster-management"},
+		Data: map[string]string{
+			sdktls.ConfigMapKeyMinVersion:   "VersionTLS12",
+			sdktls.ConfigMapKeyCipherSuites: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		},
+	}
+
+	hubProfile := NewHubTLSProfile(kubefake.NewClientset(), configfake.NewClientset(), "open-cluster-management")
+
+	changed, err := hubProfile.Refresh(context.TODO())
+	if err != nil || changed || hubProfile.Get() != nil {
+		t.Fatalf("expected no hub TLS profile on Kubernetes, got: %v, %v, %+v", changed, err, hubProfile.Get())
+	}
+
+	hubProfile = NewHubTLSProfile(kubefake.NewClientset(), configfake.NewClientset(apiServer), "open-cluster-management")
+
+	changed, err = hubProfile.Refresh(context.TODO())
+	if err != nil || !changed {
+		t.Fatalf("expected the hub TLS profile to change, got: %v, %v", changed, err)
+	}
+
+	if profile := hubProfile.Get(); profile.MinVersion != "VersionTLS13" ||
+		!strings.Contains(profile.Source, "Modern") {
+		t.Fatalf("expected the Modern profile of the APIServer, got: %+v", profile)
+	}
+
+	hubProfile = NewHubTLSProfile(
+		kubefake.NewClientset(configMap), configfake.NewClientset(apiServer), "open-cluster-management",
+	)
+
+	if _, err := hubProfile.Refresh(context.TODO()); err != nil {
+		t.Fatalf("failed to refresh the hub TLS profile: %v", err)
+	}
+
+	expected := TLSProfile{
+		MinVersion:   "VersionTLS12",
+		CipherSuites: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		Source:       "ConfigMap open-cluster-management/ocm-tls-profile",
+	}
+
+	if profile := hubProfile.Get(); *profile != expected {
+		t.Fatalf("expected the ConfigMap to take precedence over the APIServer, got: %+v", profile)
+	}
+
+	changed, err = hubProfile.Refresh(context.TODO())
+	if err != nil || changed {
+		t.Fatalf("expected the hub TLS profile to stay the same, got: %v, %v", changed, err)
+	}
+}
+
+func TestTLSProfileFromSecurityProfile(t *testing.T) {
+	profile := tlsProfileFromSecurityProfile(nil)
+
+	if profile.MinVersion != "VersionTLS12" || !strings.Contains(profile.Source, "Intermediate") {
+		t.Fatalf("expected the Intermediate profile by default, got: %+v", profile)
+	}
+
+	// The OpenSSL names are converted to IANA names and the DHE ciphers unsupported by Go are left out
+	profile = tlsProfileFromSecurityProfile(&configv1.TLSSecurityProfile{
+		Type: configv1.TLSProfileCustomType,
+		Custom: &configv1.CustomTLSProfile{
+			TLSProfileSpec: configv1.TLSProfileSpec{
+				Ciphers:       []string{"ECDHE-RSA-AES256-GCM-SHA384", "DHE-RSA-AES128-GCM-SHA256"},
+				MinTLSVersion: configv1.VersionTLS11,
+			},
+		},
+	})
+
+	if profile.MinVersion != "VersionTLS11" || profile.CipherSuites != "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384" {
+		t.Fatalf("expected the Custom profile, got: %+v", profile)
+	}
+}
+
+func TestEffectiveTLSProfile(t *testing.T) {
+	hubProfile := &TLSProfile{
+		MinVersion:   "VersionTLS12",
+		CipherSuites: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		Source:       "ConfigMap open-cluster-management/ocm-tls-profile",
+	}
+
+	minVersion, cipherSuites, reason := effectiveTLSProfile(nil, nil, nil)
+	if reason != TLSProfileReasonAgentDefault || minVersion != "the agent default" {
+		t.Fatalf("expected the agent defaults, got: %s, %s, %s", minVersion, cipherSuites, reason)
+	}
+
+	minVersion, _, reason = effectiveTLSProfile(hubProfile, nil, nil)
+	if reason != TLSProfileReasonHub || minVersion != "VersionTLS12 from "+hubProfile.Source {
+		t.Fatalf("expected the hub TLS profile, got: %s, %s", minVersion, reason)
+	}
+
+	config := &addonapiv1beta1.AddOnDeploymentConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: "cluster1"},
+		Spec: addonapiv1beta1.AddOnDeploymentConfigSpec{
+			CustomizedVariables: []addonapiv1beta1.CustomizedVariable{
+				{Name: "tlsMinVersion", Value: "VersionTLS13"},
+				{Name: "tlsCipherSuites", Value: "NOT_A_CIPHER"},
+			},
+		},
+	}
+
+	minVersion, cipherSuites, reason = effectiveTLSProfile(
+		hubProfile, map[string]string{"tlsCipherSuites": "NOT_A_CIPHER"}, config,
+	)
+	if reason != TLSProfileReasonAddOnDeploymentConfig ||
+		minVersion != "VersionTLS13 from AddOnDeploymentConfig cluster1/tls" ||
+		cipherSuites != hubProfile.CipherSuites+" from "+hubProfile.Source {
+		t.Fatalf("expected the valid AddOnDeploymentConfig variables to win, got: %s, %s, %s",
+			minVersion, cipherSuites, reason)
+	}
+}